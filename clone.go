@@ -0,0 +1,50 @@
+package leases
+
+import "net"
+
+/*
+Clone returns a deep copy of l, so the copy can be changed without changing l through the slices
+and maps they would otherwise share
+*/
+func (l Lease) Clone() Lease {
+	c := l
+	c.IP = cloneBytes(l.IP)
+	c.Hardware.MACAddr = cloneBytes(l.Hardware.MACAddr)
+	c.UIDBytes = cloneBytes(l.UIDBytes)
+	if l.Options != nil {
+		c.Options = make(map[string]string, len(l.Options))
+		for k, v := range l.Options {
+			c.Options[k] = v
+		}
+	}
+	if l.Assignments != nil {
+		c.Assignments = make(map[string]string, len(l.Assignments))
+		for k, v := range l.Assignments {
+			c.Assignments[k] = v
+		}
+	}
+	if l.Unknown != nil {
+		c.Unknown = make(map[string]string, len(l.Unknown))
+		for k, v := range l.Unknown {
+			c.Unknown[k] = v
+		}
+	}
+	if l.Subnet != nil {
+		c.Subnet = &net.IPNet{IP: cloneBytes(l.Subnet.IP), Mask: cloneBytes(l.Subnet.Mask)}
+	}
+	if l.Comments != nil {
+		c.Comments = append([]string(nil), l.Comments...)
+	}
+	if l.Errors != nil {
+		c.Errors = append([]error(nil), l.Errors...)
+	}
+	return c
+}
+
+/*cloneBytes returns a copy of b, keeping nil as nil*/
+func cloneBytes(b []byte) []byte {
+	if b == nil {
+		return nil
+	}
+	return append([]byte{}, b...)
+}
@@ -0,0 +1,41 @@
+package leases
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestClone(t *testing.T) {
+	leaseData := `
+lease 172.16.0.60 {
+  hardware ethernet 00:db:70:c3:11:d7;
+  uid "\001\000\333p\303\021\327";
+  option routers 10.0.0.1;
+}
+`
+	leases := Parse(bytes.NewBufferString(leaseData))
+	if len(leases) != 1 {
+		t.Fatalf("found %d leases, expected 1", len(leases))
+	}
+	orig := leases[0]
+	want := Parse(bytes.NewBufferString(leaseData))[0]
+
+	c := orig.Clone()
+	if !reflect.DeepEqual(c, orig) {
+		t.Fatalf("clone %v differs from %v", c, orig)
+	}
+
+	c.IP[len(c.IP)-1] = 99
+	c.Hardware.MACAddr[0] = 0xff
+	c.UIDBytes[0] = 0xff
+	c.Options["routers"] = "10.0.0.2"
+	c.Options["domain-name"] = `"example.com"`
+	if !reflect.DeepEqual(orig, want) {
+		t.Errorf("changing the clone changed the original to %v", orig)
+	}
+
+	if empty := (Lease{}).Clone(); !reflect.DeepEqual(empty, Lease{}) {
+		t.Errorf("clone of an empty lease is %v", empty)
+	}
+}
@@ -0,0 +1,187 @@
+package leases
+
+import (
+	"bytes"
+	"errors"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"net"
+	"strings"
+)
+
+/*
+ParseConfig reads a dhcpd.conf style file and returns the host declarations and any lease blocks in
+it, descending into the subnet, subnet6, shared-network, group and pool blocks around them.  Each
+record has Subnet set to the subnet it was declared in, if any.  Host declarations such as:
+
+	subnet 10.0.0.0 netmask 255.255.255.0 {
+		host printer {
+			hardware ethernet 00:db:70:c3:11:d7;
+			fixed-address 10.0.0.5;
+		}
+	}
+
+become a reserved Lease with Host set to the declaration's name and IP to its fixed address.  Other
+statements are ignored.  ErrTruncated is returned if the input ends part way through a block, along
+with the records before it.
+*/
+func ParseConfig(r io.Reader) ([]Lease, error) {
+	d, err := io.ReadAll(ioErrorReader{r})
+	if err != nil {
+		return nil, err
+	}
+
+	var rtn []Lease
+	err = parseConfigBlock(d, nil, func(l Lease) {
+		rtn = append(rtn, l)
+	})
+	return rtn, err
+}
+
+/*parseConfigBlock calls fn with each host and lease declared in d, the body of a block in subnet*/
+func parseConfigBlock(d []byte, subnet *net.IPNet, fn func(Lease)) error {
+	for {
+		d = skipConfigSpace(d)
+		if len(d) == 0 {
+			return nil
+		}
+
+		end, found := blockEnd(d)
+		if !found {
+			return ErrTruncated
+		}
+		stmt := d[:end]
+		d = d[end:]
+
+		brace := bytes.IndexByte(stmt, '{')
+		if brace == -1 || stmt[len(stmt)-1] != '}' {
+			// a single statement, such as an option
+			continue
+		}
+		header := strings.Fields(string(stmt[:brace]))
+		body := stmt[brace+1 : len(stmt)-1]
+		if len(header) == 0 {
+			continue
+		}
+
+		switch header[0] {
+		case "subnet", "subnet6":
+			n := parseSubnet(header)
+			if n == nil {
+				log.WithFields(log.Fields{"subnet": strings.Join(header, " ")}).Warn("Unable to decode subnet declaration")
+			}
+			if err := parseConfigBlock(body, n, fn); err != nil {
+				return err
+			}
+		case "shared-network", "group", "pool", "pool6":
+			if err := parseConfigBlock(body, subnet, fn); err != nil {
+				return err
+			}
+		case "host":
+			fn(parseHost(header, body, subnet))
+		case "lease":
+			l := Lease{}
+			block := append(append([]byte{}, stmt[:brace+1]...), '\n')
+			l.parse(append(block, configStatements(body)...), &ParseOptions{})
+			l.Subnet = subnet
+			fn(l)
+		}
+	}
+}
+
+/*skipConfigSpace returns d without its leading whitespace, comments and stray ';'*/
+func skipConfigSpace(d []byte) []byte {
+	for len(d) > 0 {
+		switch d[0] {
+		case ' ', '\t', '\r', '\n', ';':
+			d = d[1:]
+		case '#':
+			i := bytes.IndexByte(d, '\n')
+			if i == -1 {
+				return nil
+			}
+			d = d[i+1:]
+		default:
+			return d
+		}
+	}
+	return d
+}
+
+/*
+parseSubnet returns the network of a "subnet 10.0.0.0 netmask 255.255.255.0" or
+"subnet6 2001:db8::/64" header, or nil if it can't be decoded
+*/
+func parseSubnet(header []string) *net.IPNet {
+	switch {
+	case header[0] == "subnet6" && len(header) >= 2:
+		if _, n, err := net.ParseCIDR(header[1]); err == nil {
+			return n
+		}
+	case header[0] == "subnet" && len(header) >= 4 && header[2] == "netmask":
+		ip, mask := net.ParseIP(header[1]).To4(), net.ParseIP(header[3]).To4()
+		if ip != nil && mask != nil {
+			return &net.IPNet{IP: ip.Mask(net.IPMask(mask)), Mask: net.IPMask(mask)}
+		}
+	}
+	return nil
+}
+
+/*parseHost returns the reservation made by a host declaration with the given header and body*/
+func parseHost(header []string, body []byte, subnet *net.IPNet) Lease {
+	l := Lease{Reserved: true, Subnet: subnet}
+	if len(header) > 1 {
+		l.Host = strings.Trim(header[1], "\"")
+	}
+
+	lines := configStatements(body)
+	l.parse(lines, &ParseOptions{})
+	for _, line := range strings.Split(string(lines), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) > 0 && (fields[0] == "fixed-address" || fields[0] == "fixed-address6") {
+			if len(fields) < 2 || strings.TrimRight(fields[1], ",;") == "" {
+				l.addError(line, errors.New("fixed-address statement without an address"))
+				continue
+			}
+			// only the first address of a list is kept
+			l.RawAddress = strings.TrimRight(fields[1], ",;")
+			l.IP = net.ParseIP(l.RawAddress)
+		}
+	}
+	delete(l.Unknown, "fixed-address")
+	delete(l.Unknown, "fixed-address6")
+	if len(l.Unknown) == 0 {
+		l.Unknown = nil
+	}
+	return l
+}
+
+/*
+configStatements returns the statements in d, the body of a block, one to a line with comments and
+indentation removed, for decoding line by line.  Config files often put several statements on one
+line, or follow a statement with a comment.  A statement missing its ';' at the end of d is kept as
+written.
+*/
+func configStatements(d []byte) []byte {
+	var b bytes.Buffer
+	for {
+		d = skipConfigSpace(d)
+		if len(d) == 0 {
+			return b.Bytes()
+		}
+		end, found := blockEnd(d)
+		if !found {
+			end = len(d)
+		}
+		stmt := bytes.TrimSpace(d[:end])
+		d = d[end:]
+		// a statement spread over several lines is put on one
+		b.Write(bytes.Map(func(r rune) rune {
+			if r == '\n' || r == '\r' || r == '\t' {
+				return ' '
+			}
+			return r
+		}, stmt))
+		b.WriteByte('\n')
+	}
+}
@@ -0,0 +1,111 @@
+package leases
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestParseConfig(t *testing.T) {
+	config := `# dhcpd.conf
+option domain-name "example.com";
+default-lease-time 600;
+
+subnet 10.0.0.0 netmask 255.255.255.0 {
+	option routers 10.0.0.1;
+	pool {
+		range 10.0.0.100 10.0.0.200;
+	}
+	host printer {
+		hardware ethernet 00:db:70:c3:11:d7;
+		fixed-address 10.0.0.5;
+	}
+	group {
+		host "laptop" {
+			hardware ethernet 00:db:70:c3:11:d8;
+			fixed-address 10.0.0.6, 10.0.0.7;
+		}
+	}
+}
+
+shared-network office {
+	subnet6 2001:db8::/64 {
+		host phone {
+			fixed-address6 2001:db8::5;
+		}
+	}
+}
+
+host roaming {
+	hardware ethernet 00:db:70:c3:11:d9;
+}
+
+host a { hardware ethernet 00:11:22:33:44:55; fixed-address 10.0.0.8; }
+host b {
+	hardware ethernet 00:11:22:33:44:56; # nic
+	fixed-address 10.0.0.9; # desk
+}
+
+lease 10.0.0.150 {
+  binding state active;
+}
+`
+	records, err := ParseConfig(bytes.NewBufferString(config))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	want := []struct {
+		host   string
+		ip     string
+		mac    string
+		subnet string
+	}{
+		{"printer", "10.0.0.5", "00:db:70:c3:11:d7", "10.0.0.0/24"},
+		{"laptop", "10.0.0.6", "00:db:70:c3:11:d8", "10.0.0.0/24"},
+		{"phone", "2001:db8::5", "", "2001:db8::/64"},
+		{"roaming", "<nil>", "00:db:70:c3:11:d9", "<nil>"},
+		{"a", "10.0.0.8", "00:11:22:33:44:55", "<nil>"},
+		{"b", "10.0.0.9", "00:11:22:33:44:56", "<nil>"},
+		{"", "10.0.0.150", "", "<nil>"},
+	}
+	if len(records) != len(want) {
+		t.Fatalf("found %d records, expected %d: %v", len(records), len(want), records)
+	}
+	for i, w := range want {
+		r := records[i]
+		subnet := "<nil>"
+		if r.Subnet != nil {
+			subnet = r.Subnet.String()
+		}
+		if r.Host != w.host || r.IP.String() != w.ip || r.Hardware.MAC != w.mac || subnet != w.subnet {
+			t.Errorf("record %d is %q %s %q in %s, expected %q %s %q in %s", i, r.Host, r.IP, r.Hardware.MAC, subnet, w.host, w.ip, w.mac, w.subnet)
+		}
+		if r.Reserved != (w.host != "") {
+			t.Errorf("record %d reserved is %v", i, r.Reserved)
+		}
+	}
+	if records[len(records)-1].BindingState != "active" {
+		t.Errorf("lease binding state is %q, expected active", records[len(records)-1].BindingState)
+	}
+
+	if _, err := ParseConfig(bytes.NewBufferString("subnet 10.0.0.0 netmask 255.255.255.0 {\n\thost a {\n")); !errors.Is(err, ErrTruncated) {
+		t.Errorf("expected ErrTruncated, got %v", err)
+	}
+}
+
+func TestParseConfigFixedAddressWithoutAddress(t *testing.T) {
+	config := `host printer {
+	hardware ethernet 00:db:70:c3:11:d7;
+	fixed-address 
+}
+`
+	leases, err := ParseConfig(bytes.NewBufferString(config))
+	if err != nil || len(leases) != 1 {
+		t.Fatalf("expected 1 host, got %d, %v", len(leases), err)
+	}
+	l := leases[0]
+	if l.IP != nil || len(l.Errors) != 1 || !errors.Is(l.Errors[0], ErrMalformedLease) {
+		t.Errorf("expected no IP and a malformed statement error, got %v, %v", l.IP, l.Errors)
+	}
+}
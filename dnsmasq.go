@@ -0,0 +1,77 @@
+package leases
+
+import (
+	"bufio"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+ParseDnsmasq reads a dnsmasq lease file and returns its leases.  Each line of the file is a lease:
+
+	1556336085 00:db:70:c3:11:d7 172.24.43.3 m8 01:00:db:70:c3:11:d7
+
+giving the expiry time in seconds since the epoch, or 0 for leases that never expire, then the MAC
+address, IP, hostname and client identifier, with * for any that are missing.  dnsmasq only
+records current leases, so each is given the active binding state.  The duid line and the IAID of
+DHCPv6 leases are skipped.  An error is returned for a line that can't be parsed, along with the
+leases before it.
+*/
+func ParseDnsmasq(r io.Reader) ([]Lease, error) {
+	var rtn []Lease
+
+	scanner := bufio.NewScanner(ioErrorReader{r})
+	for n := 1; scanner.Scan(); n++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || fields[0] == "duid" {
+			continue
+		}
+		if len(fields) < 4 {
+			return rtn, fmt.Errorf("dnsmasq lease line %d: expected at least 4 fields, got %d", n, len(fields))
+		}
+		for i, f := range fields {
+			if f == "*" {
+				fields[i] = ""
+			}
+		}
+
+		expiry, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			return rtn, fmt.Errorf("dnsmasq lease line %d: decoding expiry: %w", n, err)
+		}
+
+		l := Lease{BindingState: "active", RawAddress: fields[2], ClientHostname: fields[3]}
+		l.IP = net.ParseIP(l.RawAddress)
+		l.Ends = Never
+		if expiry != 0 {
+			l.Ends = time.Unix(expiry, 0).UTC()
+		}
+		if l.IP.To4() != nil && fields[1] != "" {
+			// DHCPv6 leases have the IAID here instead
+			l.Hardware.Hardware = "ethernet"
+			l.Hardware.MAC = fields[1]
+			if m, e := net.ParseMAC(fields[1]); e == nil {
+				l.Hardware.MACAddr = m
+			}
+		}
+		if len(fields) > 4 && fields[4] != "" {
+			l.UID = fields[4]
+			if b, err := parseHexList(l.UID); err == nil {
+				l.UIDBytes = b
+			} else {
+				l.addError(fields[4], fmt.Errorf("decoding uid %q: %w", l.UID, err))
+			}
+		}
+
+		log.WithFields(log.Fields{
+			"lease": l,
+		}).Trace("Parsed dnsmasq lease")
+		rtn = append(rtn, l)
+	}
+	return rtn, scanner.Err()
+}
@@ -0,0 +1,53 @@
+package leases
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestParseDnsmasq(t *testing.T) {
+	leaseData := `1556336085 00:db:70:c3:11:d7 172.24.43.3 m8 01:00:db:70:c3:11:d7
+0 00:db:70:c3:11:d8 172.24.43.4 * *
+duid 00:01:00:01:1e:8d:ea:f3:00:0c:29:af:00:01
+1556336085 27230 2001:db8::100 v6host 00:01:00:01:1e:8d:ea:f3:00:0c:29:af:00:01
+`
+	leases, err := ParseDnsmasq(bytes.NewBufferString(leaseData))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(leases) != 3 {
+		t.Fatalf("found %d leases, expected 3", len(leases))
+	}
+
+	l := leases[0]
+	if l.IP.String() != "172.24.43.3" || l.ClientHostname != "m8" || l.Hardware.MACAddr.String() != "00:db:70:c3:11:d7" {
+		t.Errorf("unexpected lease %s %s %s", l.IP, l.ClientHostname, l.Hardware.MACAddr)
+	}
+	if want := time.Unix(1556336085, 0); !l.Ends.Equal(want) {
+		t.Errorf("ends %v, expected %v", l.Ends, want)
+	}
+	if !bytes.Equal(l.UIDBytes, []byte{1, 0, 0xdb, 0x70, 0xc3, 0x11, 0xd7}) {
+		t.Errorf("uid %x", l.UIDBytes)
+	}
+	if !l.IsActive(time.Unix(1556336000, 0)) {
+		t.Error("lease should be active before it expires")
+	}
+
+	l = leases[1]
+	if !l.Ends.Equal(Never) || l.ClientHostname != "" || l.UID != "" {
+		t.Errorf("unexpected lease %v, %q, %q", l.Ends, l.ClientHostname, l.UID)
+	}
+
+	l = leases[2]
+	if l.IP.String() != "2001:db8::100" || l.Hardware.MAC != "" || l.ClientHostname != "v6host" {
+		t.Errorf("unexpected lease %s %q %s", l.IP, l.Hardware.MAC, l.ClientHostname)
+	}
+
+	if _, err := ParseDnsmasq(bytes.NewBufferString("soon 00:db:70:c3:11:d7 172.24.43.3 m8\n")); err == nil {
+		t.Error("expected an error for a bad expiry")
+	}
+	if leases, err := ParseDnsmasq(bytes.NewBufferString(leaseData + "1556336085 00:db:70:c3:11:d9\n")); err == nil || len(leases) != 3 {
+		t.Errorf("expected an error and 3 leases for a short line, got %v and %d", err, len(leases))
+	}
+}
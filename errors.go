@@ -0,0 +1,64 @@
+package leases
+
+import (
+	"fmt"
+	"io"
+)
+
+/*
+MalformedLeaseError is recorded in Lease.Errors for each statement in a lease block that can't be
+decoded.  errors.Is matches it with ErrMalformedLease as well as with the error it wraps, such as
+ErrNestedLease.
+*/
+type MalformedLeaseError struct {
+	//Offset is the position in bytes of the lease block in the parsed stream, 0 where the lease wasn't read from a stream
+	Offset int64
+
+	//Line is the statement that couldn't be decoded
+	Line string
+
+	//Err is the problem with the statement
+	Err error
+}
+
+func (e *MalformedLeaseError) Error() string {
+	return fmt.Sprintf("lease at offset %d: %v", e.Offset, e.Err)
+}
+
+func (e *MalformedLeaseError) Unwrap() error {
+	return e.Err
+}
+
+func (e *MalformedLeaseError) Is(target error) bool {
+	return target == ErrMalformedLease
+}
+
+/*ioError wraps an error reading the input so errors.Is matches it with ErrIO as well as the error itself*/
+type ioError struct {
+	err error
+}
+
+func (e ioError) Error() string {
+	return ErrIO.Error() + ": " + e.err.Error()
+}
+
+func (e ioError) Unwrap() error {
+	return e.err
+}
+
+func (e ioError) Is(target error) bool {
+	return target == ErrIO
+}
+
+/*ioErrorReader wraps the errors, other than io.EOF, returned by r in ioError*/
+type ioErrorReader struct {
+	r io.Reader
+}
+
+func (e ioErrorReader) Read(p []byte) (int, error) {
+	n, err := e.r.Read(p)
+	if err != nil && err != io.EOF {
+		err = ioError{err}
+	}
+	return n, err
+}
@@ -0,0 +1,97 @@
+package leases
+
+import (
+	"strconv"
+	"strings"
+)
+
+/*
+unescape decodes the escapes dhcpd writes in quoted strings: a backslash followed by three octal
+digits for non-printable bytes, and \\ or \" for literal backslashes and quotes.  The \xHH hex
+escapes some other tools write are also decoded.
+*/
+func unescape(s string) []byte {
+	b := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b = append(b, s[i])
+			continue
+		}
+		if i+3 < len(s) && isOctal(s[i+1]) && isOctal(s[i+2]) && isOctal(s[i+3]) {
+			b = append(b, (s[i+1]-'0')<<6|(s[i+2]-'0')<<3|(s[i+3]-'0'))
+			i += 3
+			continue
+		}
+		if i+3 < len(s) && s[i+1] == 'x' && isHex(s[i+2]) && isHex(s[i+3]) {
+			v, _ := strconv.ParseUint(s[i+2:i+4], 16, 8)
+			b = append(b, byte(v))
+			i += 3
+			continue
+		}
+		// any other escaped character stands for itself
+		i++
+		b = append(b, s[i])
+	}
+	return b
+}
+
+func isOctal(c byte) bool {
+	return c >= '0' && c <= '7'
+}
+
+func isHex(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
+
+/*parseHexList decodes a colon separated list of hexadecimal octets such as "1:0:db:70:c3:11:d7"*/
+func parseHexList(s string) ([]byte, error) {
+	octets := strings.Split(s, ":")
+	b := make([]byte, 0, len(octets))
+	for _, o := range octets {
+		v, err := strconv.ParseUint(o, 16, 8)
+		if err != nil {
+			return nil, err
+		}
+		b = append(b, byte(v))
+	}
+	return b, nil
+}
+
+/*
+escape quotes b the way dhcpd writes quoted strings, with non-printable bytes as a backslash
+followed by three octal digits
+*/
+func escape(b []byte) string {
+	var sb strings.Builder
+	for _, c := range b {
+		switch {
+		case c == '"' || c == '\\':
+			sb.WriteByte('\\')
+			sb.WriteByte(c)
+		case c < 0x20 || c > 0x7e:
+			sb.WriteByte('\\')
+			sb.WriteByte('0' + c>>6)
+			sb.WriteByte('0' + c>>3&7)
+			sb.WriteByte('0' + c&7)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
+
+/*
+escapeRaw escapes any non-printable bytes in s, which is otherwise already quoted the way dhcpd
+writes it, so a control character such as a newline can't end up inside a quoted string
+*/
+func escapeRaw(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c < 0x20 || c > 0x7e {
+			sb.WriteString(escape([]byte{c}))
+		} else {
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
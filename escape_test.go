@@ -0,0 +1,37 @@
+package leases
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestUnescape(t *testing.T) {
+	for in, want := range map[string][]byte{
+		`\001\000\333p\303\021\327`: {0x01, 0x00, 0xdb, 'p', 0xc3, 0x11, 0xd7},
+		`\377\"\305`:                {0xff, '"', 0xc5},
+		`b\\`:                       {'b', '\\'},
+		`plain`:                     []byte("plain"),
+		`\12`:                       []byte(`12`),
+		`\xff\x0Aa`:                 {0xff, 0x0a, 'a'},
+		`\001\xdb\\x\"\377`:         {0x01, 0xdb, '\\', 'x', '"', 0xff},
+		`\xg1`:                      []byte(`xg1`),
+	} {
+		if got := unescape(in); !bytes.Equal(got, want) {
+			t.Errorf("unescape(%q) = %v, expected %v", in, got, want)
+		}
+	}
+}
+
+func TestParseHexList(t *testing.T) {
+	got, err := parseHexList("1:0:db:70:c3:11:d7")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if want := []byte{0x01, 0x00, 0xdb, 0x70, 0xc3, 0x11, 0xd7}; !bytes.Equal(got, want) {
+		t.Errorf("got %v, expected %v", got, want)
+	}
+
+	if _, err := parseHexList("01:zz"); err == nil {
+		t.Error("expected an error for invalid hex")
+	}
+}
@@ -0,0 +1,159 @@
+package leases
+
+import (
+	"bufio"
+	"bytes"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+FailoverState is the state of a failover peer as recorded in the leases file:
+
+	failover peer "dhcp-failover" state {
+		my state partner-down at 4 2019/04/25 12:00:00;
+		partner state communications-interrupted at 4 2019/04/25 11:59:00;
+		mclt 3600;
+	}
+*/
+type FailoverState struct {
+	//Name of the failover peer
+	Peer string `json:"peer"`
+
+	//State of this server and the time it entered that state
+	MyState     string    `json:"my-state"`
+	MyStateTime time.Time `json:"my-state-time"`
+
+	//State of the failover partner and the time it entered that state
+	PartnerState     string    `json:"partner-state"`
+	PartnerStateTime time.Time `json:"partner-state-time"`
+
+	//Maximum client lead time
+	MCLT time.Duration `json:"mclt"`
+
+	//PartnerDown is the time either side of the pair entered the partner-down state, or the zero time if neither has
+	PartnerDown time.Time `json:"partner-down"`
+}
+
+var (
+	failoverDecoders = map[string]func(*FailoverState, string){
+		"failover peer ": func(f *FailoverState, line string) { f.Peer = parseQuotedField(line) },
+		"my state ": func(f *FailoverState, line string) {
+			f.MyState, f.MyStateTime = parseFailoverState(line)
+		},
+		// dhcpd.leases(5) documents "peer state" but dhcpd writes "partner state"
+		"peer state ": func(f *FailoverState, line string) {
+			f.PartnerState, f.PartnerStateTime = parseFailoverState(line)
+		},
+		"partner state ": func(f *FailoverState, line string) {
+			f.PartnerState, f.PartnerStateTime = parseFailoverState(line)
+		},
+		"mclt ": func(f *FailoverState, line string) {
+			if s, err := strconv.Atoi(parseKeyword(line, 1)); err == nil {
+				f.MCLT = time.Duration(s) * time.Second
+			}
+		},
+	}
+)
+
+/*parseQuotedField returns the first quoted string in s*/
+func parseQuotedField(s string) string {
+	v, _ := quotedValue(s)
+	return v
+}
+
+/*parseFailoverState parses "my state partner-down at 4 2019/04/25 12:00:00;" into its state and time*/
+func parseFailoverState(s string) (string, time.Time) {
+	s = strings.TrimRight(s, ";")
+	parts := strings.SplitN(s, " ", 5)
+	if len(parts) < 3 {
+		return "", time.Time{}
+	}
+	if len(parts) < 5 || parts[3] != "at" {
+		return parts[2], time.Time{}
+	}
+	// the time may be followed by a comment, as dhcpd does for the epoch form
+	return parts[2], parseDate(timeValue(parts[4]))
+}
+
+/*parse populates f from a failover peer state block*/
+func (f *FailoverState) parse(s []byte) {
+	log.WithField("failoverToken", s).Trace("Parsing failover token")
+	scanner := bufio.NewScanner(bytes.NewBuffer(s))
+	scanner.Split(bufio.ScanLines)
+	for scanner.Scan() {
+		line := strings.TrimLeft(scanner.Text(), " \t")
+
+		for prefix, parser := range failoverDecoders {
+			if strings.HasPrefix(line, prefix) {
+				parser(f, line)
+			}
+		}
+	}
+
+	switch {
+	case f.MyState == "partner-down":
+		f.PartnerDown = f.MyStateTime
+	case f.PartnerState == "partner-down":
+		f.PartnerDown = f.PartnerStateTime
+	}
+}
+
+/*
+ParseFailover reads from a dhcpd.leases file and returns the failover peer states recorded in it.
+Leases and unknown fields are ignored
+*/
+func ParseFailover(r io.Reader) ([]FailoverState, error) {
+	var rtn []FailoverState
+
+	t := newTokenizer(failoverStartKeywords, 0)
+	err := scanBlocks(r, t, func(block []byte) {
+		f := FailoverState{}
+		f.parse(block)
+		log.WithFields(log.Fields{
+			"failover": f,
+		}).Trace("Parsed failover state")
+		rtn = append(rtn, f)
+	})
+	return rtn, err
+}
+
+var (
+	//bindingStates are the binding states dhcpd writes
+	bindingStates = map[string]bool{
+		"free": true, "active": true, "expired": true, "released": true, "abandoned": true,
+		"reset": true, "backup": true, "reserved": true, "bootp": true,
+	}
+)
+
+/*
+RewindTo returns the binding state the lease goes back to if the failover partner never received
+its latest change, the rewind binding state.  The lease's current binding state is returned if no
+rewind binding state is recorded, as it hasn't changed since the partner last acknowledged it.
+*/
+func (l Lease) RewindTo() string {
+	if l.RewindBindingState == "" {
+		return l.BindingState
+	}
+	return l.RewindBindingState
+}
+
+/*
+RewindConsistent returns false if the lease's rewind binding state doesn't fit with the rest of it:
+an unknown state, or a state other than the current binding state once the partner has acknowledged
+the lease, which it has when tsfp has caught up with tstp.  Leases without a rewind binding state
+are always consistent.
+*/
+func (l Lease) RewindConsistent() bool {
+	if l.RewindBindingState == "" {
+		return true
+	}
+	if !bindingStates[l.RewindBindingState] {
+		return false
+	}
+	acked := !l.Tstp.IsZero() && !l.Tsfp.IsZero() && !l.Tsfp.Before(l.Tstp)
+	return !acked || l.RewindBindingState == l.BindingState
+}
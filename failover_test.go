@@ -0,0 +1,123 @@
+package leases
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestParseFailover(t *testing.T) {
+	leaseData := `
+failover peer "dhcp-failover" state {
+  my state partner-down at 4 2019/04/25 12:00:00;
+  partner state communications-interrupted at 4 2019/04/25 11:59:00;
+  mclt 3600;
+}
+
+lease 172.24.43.3 {
+  starts 6 2019/04/27 03:24:45;
+  binding state active;
+}
+
+failover peer "other" state {
+  my state normal at 4 2019/04/25 10:00:00;
+  partner state normal at 4 2019/04/25 10:00:01;
+}
+`
+	states, err := ParseFailover(bytes.NewBufferString(leaseData))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(states) != 2 {
+		t.Fatalf("found %d failover states, expected 2", len(states))
+	}
+
+	f := states[0]
+	if f.Peer != "dhcp-failover" {
+		t.Errorf("peer %q should be dhcp-failover", f.Peer)
+	}
+	if f.MyState != "partner-down" || f.PartnerState != "communications-interrupted" {
+		t.Errorf("unexpected states %q and %q", f.MyState, f.PartnerState)
+	}
+	if want := time.Date(2019, 4, 25, 11, 59, 0, 0, time.UTC); !f.PartnerStateTime.Equal(want) {
+		t.Errorf("partner state time %v should be %v", f.PartnerStateTime, want)
+	}
+	if f.MCLT != time.Hour {
+		t.Errorf("mclt %v should be 1h", f.MCLT)
+	}
+	if want := time.Date(2019, 4, 25, 12, 0, 0, 0, time.UTC); !f.PartnerDown.Equal(want) {
+		t.Errorf("partner down %v should be %v", f.PartnerDown, want)
+	}
+
+	if !states[1].PartnerDown.IsZero() {
+		t.Errorf("%v shouldn't be partner down", states[1])
+	}
+}
+
+func TestParseFailoverEpoch(t *testing.T) {
+	leaseData := `
+failover peer "dhcp-failover" state {
+  my state partner-down at epoch 1556335485; # Sat Apr 27 03:24:45 2019
+  partner state normal at epoch 1556335425; # Sat Apr 27 03:23:45 2019
+}
+`
+	states, err := ParseFailover(bytes.NewBufferString(leaseData))
+	if err != nil || len(states) != 1 {
+		t.Fatalf("expected 1 failover state, got %d, %v", len(states), err)
+	}
+	f := states[0]
+	if want := time.Unix(1556335485, 0).UTC(); !f.MyStateTime.Equal(want) || !f.PartnerDown.Equal(want) {
+		t.Errorf("my state time %v and partner down %v should be %v", f.MyStateTime, f.PartnerDown, want)
+	}
+	if want := time.Unix(1556335425, 0).UTC(); !f.PartnerStateTime.Equal(want) {
+		t.Errorf("partner state time %v should be %v", f.PartnerStateTime, want)
+	}
+}
+
+func TestRewindConsistent(t *testing.T) {
+	leaseData := `
+lease 172.24.43.3 {
+  tstp 6 2019/04/27 03:34:45;
+  tsfp 6 2019/04/27 03:34:45;
+  binding state active;
+  rewind binding state active;
+}
+lease 172.24.43.4 {
+  tstp 6 2019/04/27 03:34:45;
+  tsfp 6 2019/04/27 03:24:45;
+  binding state active;
+  rewind binding state free;
+}
+lease 172.24.43.5 {
+  tstp 6 2019/04/27 03:34:45;
+  tsfp 6 2019/04/27 03:34:45;
+  binding state active;
+  rewind binding state free;
+}
+lease 172.24.43.6 {
+  binding state free;
+  rewind binding state bogus;
+}
+lease 172.24.43.7 {
+  binding state free;
+}
+`
+	leases := Parse(bytes.NewBufferString(leaseData))
+	for i, want := range []struct {
+		rewindTo   string
+		consistent bool
+	}{
+		{"active", true},
+		{"free", true},
+		{"free", false},
+		{"bogus", false},
+		{"free", true},
+	} {
+		if got := leases[i].RewindTo(); got != want.rewindTo {
+			t.Errorf("lease %d rewinds to %s, expected %s", i, got, want.rewindTo)
+		}
+		if got := leases[i].RewindConsistent(); got != want.consistent {
+			t.Errorf("lease %d rewind consistent is %v, expected %v", i, got, want.consistent)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package leases
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"io"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	gzipMagic  = []byte{0x1f, 0x8b}
+	bzip2Magic = []byte("BZh")
+)
+
+/*
+ParseFile opens the dhcpd.leases file at path and returns the leases in it.  gzip and bzip2
+compressed files, such as rotated lease files, are decompressed, including files of several
+concatenated gzip members.
+*/
+func ParseFile(path string) ([]Lease, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r, err := decompress(f)
+	if err != nil {
+		return nil, err
+	}
+	return ParseWithOptions(r, ParseOptions{})
+}
+
+/*
+ParseCompressedBytes parses leases from b, such as a lease file fetched from an API, decompressing it
+first if it is gzip or bzip2 compressed
+*/
+func ParseCompressedBytes(b []byte) ([]Lease, error) {
+	if !bytes.HasPrefix(b, gzipMagic) && !bytes.HasPrefix(b, bzip2Magic) {
+		return ParseBytes(b)
+	}
+	r, err := decompress(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	return ParseWithOptions(r, ParseOptions{})
+}
+
+/*
+ParseFileTagged is like ParseFile but also records path as the Source of each lease, to tell leases
+ingested from several servers' files apart
+*/
+func ParseFileTagged(path string) ([]Lease, error) {
+	leases, err := ParseFile(path)
+	for i := range leases {
+		leases[i].Source = path
+	}
+	return leases, err
+}
+
+/*
+FileErrors maps the paths of the files ParseFiles couldn't parse fully to the error for each
+*/
+type FileErrors map[string]error
+
+func (e FileErrors) Error() string {
+	paths := make([]string, 0, len(e))
+	for path := range e {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	msgs := make([]string, len(paths))
+	for i, path := range paths {
+		msgs[i] = path + ": " + e[path].Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+/*
+ParseFiles parses the files at paths as ParseFile does, up to concurrency at a time, and returns the
+leases of each keyed by path.  concurrency defaults to the number of CPUs if it isn't positive.  A
+file that can't be parsed doesn't stop the others; its error is returned in a FileErrors along with
+any leases read from it.
+*/
+func ParseFiles(paths []string, concurrency int) (map[string][]Lease, error) {
+	if concurrency <= 0 {
+		concurrency = runtime.NumCPU()
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	rtn := make(map[string][]Lease, len(paths))
+	errs := FileErrors{}
+
+	work := make(chan string)
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range work {
+				leases, err := ParseFile(path)
+				mu.Lock()
+				rtn[path] = leases
+				if err != nil {
+					errs[path] = err
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, path := range paths {
+		work <- path
+	}
+	close(work)
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return rtn, errs
+	}
+	return rtn, nil
+}
+
+/*decompress returns a reader decompressing r if it starts with gzip's or bzip2's magic number, or r as is*/
+func decompress(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(len(bzip2Magic))
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		// gzip.Reader reads every member of a multistream file by default
+		return gzip.NewReader(br)
+	case bytes.HasPrefix(magic, bzip2Magic):
+		return bzip2.NewReader(br), nil
+	}
+	return br, nil
+}
@@ -0,0 +1,162 @@
+package leases
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseFileTagged(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dhcpd.leases")
+	if err := os.WriteFile(path, []byte(braceFixture), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	leases, err := ParseFileTagged(path)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(leases) != 3 {
+		t.Fatalf("found %d leases, expected 3", len(leases))
+	}
+	for _, l := range leases {
+		if l.Source != path {
+			t.Errorf("%s has source %q, expected %q", l.IP, l.Source, path)
+		}
+	}
+
+	plain, err := ParseFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	for _, l := range plain {
+		if l.Source != "" {
+			t.Errorf("%s shouldn't have a source, got %q", l.IP, l.Source)
+		}
+	}
+
+	if _, err := ParseFileTagged(filepath.Join(t.TempDir(), "missing")); !os.IsNotExist(err) {
+		t.Errorf("expected a not exist error, got %v", err)
+	}
+}
+
+func TestParseFileGzipMembers(t *testing.T) {
+	var b bytes.Buffer
+	for _, member := range []string{braceFixture, uidQuoteFixture} {
+		w := gzip.NewWriter(&b)
+		if _, err := w.Write([]byte(member)); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "dhcpd.leases.gz")
+	if err := os.WriteFile(path, b.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	leases, err := ParseFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	want := []string{"172.16.0.60", "172.16.0.67", "172.16.0.219", "172.16.0.66", "172.16.0.24"}
+	if len(leases) != len(want) {
+		t.Fatalf("found %d leases, expected %d", len(leases), len(want))
+	}
+	for i, ip := range want {
+		if leases[i].IP.String() != ip {
+			t.Errorf("lease %d is %s, expected %s", i, leases[i].IP, ip)
+		}
+	}
+}
+
+func TestParseCompressedBytes(t *testing.T) {
+	leaseData := "\nlease 172.16.0.60 {\n  binding state active;\n}\n"
+
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	if _, err := w.Write([]byte(leaseData)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// leaseData compressed with bzip2 -9, there being no bzip2 writer in the standard library
+	bz := []byte{
+		0x42, 0x5a, 0x68, 0x39, 0x31, 0x41, 0x59, 0x26, 0x53, 0x59, 0x6e, 0xda,
+		0x32, 0x86, 0x00, 0x00, 0x01, 0x59, 0x80, 0x00, 0x10, 0x40, 0x01, 0x71,
+		0x88, 0x3e, 0xa5, 0x0d, 0x0a, 0x20, 0x00, 0x22, 0x29, 0xed, 0x53, 0xf4,
+		0xa3, 0x4f, 0x44, 0x01, 0xed, 0x50, 0xa0, 0x03, 0x11, 0xa6, 0x9a, 0x34,
+		0x48, 0x4b, 0xf2, 0xd1, 0xd5, 0xaf, 0x70, 0x25, 0xa3, 0x18, 0xcd, 0xec,
+		0x5e, 0x87, 0x1f, 0x51, 0x48, 0xfb, 0x51, 0xda, 0x6d, 0xa6, 0x42, 0x26,
+		0x3f, 0x17, 0x72, 0x45, 0x38, 0x50, 0x90, 0x6e, 0xda, 0x32, 0x86,
+	}
+
+	for name, b := range map[string][]byte{
+		"plain": []byte(leaseData),
+		"gzip":  gz.Bytes(),
+		"bzip2": bz,
+	} {
+		leases, err := ParseCompressedBytes(b)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", name, err)
+			continue
+		}
+		if len(leases) != 1 || leases[0].IP.String() != "172.16.0.60" || leases[0].BindingState != "active" {
+			t.Errorf("%s: unexpected leases %v", name, leases)
+		}
+	}
+
+	if _, err := ParseCompressedBytes(gz.Bytes()[:len(gz.Bytes())-4]); err == nil {
+		t.Error("expected an error for a truncated gzip stream")
+	}
+}
+
+func TestParseFiles(t *testing.T) {
+	dir := t.TempDir()
+	var paths []string
+	for i := 0; i < 10; i++ {
+		path := filepath.Join(dir, fmt.Sprintf("dhcpd%d.leases", i))
+		leaseData := fmt.Sprintf("\nlease 10.0.0.%d {\n  binding state active;\n}\n", i)
+		if err := os.WriteFile(path, []byte(leaseData), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+	truncated := filepath.Join(dir, "truncated.leases")
+	if err := os.WriteFile(truncated, []byte("\nlease 10.0.1.1 {\n}\nlease 10.0.1.2 {\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(dir, "missing.leases")
+	paths = append(paths, truncated, missing)
+
+	results, err := ParseFiles(paths, 3)
+	var errs FileErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("expected FileErrors, got %v", err)
+	}
+	if len(errs) != 2 || !errors.Is(errs[truncated], ErrTruncated) || !os.IsNotExist(errs[missing]) {
+		t.Errorf("unexpected errors %v", errs)
+	}
+
+	for i, path := range paths[:10] {
+		if l := results[path]; len(l) != 1 || l[0].IP.String() != fmt.Sprintf("10.0.0.%d", i) {
+			t.Errorf("%s: unexpected leases %v", path, l)
+		}
+	}
+	if l := results[truncated]; len(l) != 1 {
+		t.Errorf("expected the complete lease from the truncated file, got %v", l)
+	}
+
+	if _, err := ParseFiles(paths[:10], 0); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+}
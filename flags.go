@@ -0,0 +1,57 @@
+package leases
+
+/*
+LeaseFlags is a bitset of boolean qualifiers of a lease, for compact storage and fast filtering such
+as flags&FlagReserved != 0
+*/
+type LeaseFlags uint8
+
+const (
+	//FlagBootp is set for leases allocated to BOOTP clients
+	FlagBootp LeaseFlags = 1 << iota
+
+	//FlagReserved is set for leases reserved for a particular client
+	FlagReserved
+
+	//FlagDynamic is set for leases that are neither BOOTP nor reserved
+	FlagDynamic
+
+	//FlagAbandoned is set for leases in the abandoned binding state
+	FlagAbandoned
+)
+
+/*
+Flags returns the lease's qualifiers as a bitset.  The readable fields remain the source of the
+flags.
+*/
+func (l Lease) Flags() LeaseFlags {
+	var f LeaseFlags
+	if l.Bootp {
+		f |= FlagBootp
+	}
+	if l.Reserved {
+		f |= FlagReserved
+	}
+	if !l.Bootp && !l.Reserved {
+		f |= FlagDynamic
+	}
+	if l.BindingState == "abandoned" {
+		f |= FlagAbandoned
+	}
+	return f
+}
+
+/*
+IsReservation returns true if the lease is reserved for a particular client.  dhcpd marks
+reservations with the reserved statement whatever the binding state, so both
+
+	binding state active;
+	reserved;
+
+and, on a failover pair, "binding state backup;" with "reserved;" are reservations, as are host
+declarations read by ParseConfig.  The backup state alone is not: it marks a free lease held for
+the failover secondary to allocate dynamically.
+*/
+func (l Lease) IsReservation() bool {
+	return l.Reserved
+}
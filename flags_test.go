@@ -0,0 +1,76 @@
+package leases
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestFlags(t *testing.T) {
+	leaseData := `
+lease 10.0.0.5 {
+  binding state active;
+}
+lease 10.0.0.6 {
+  binding state active;
+  reserved;
+}
+lease 10.0.0.7 {
+  binding state active;
+  bootp;
+  reserved;
+}
+lease 10.0.0.8 {
+  binding state abandoned;
+}
+`
+	want := []LeaseFlags{
+		FlagDynamic,
+		FlagReserved,
+		FlagBootp | FlagReserved,
+		FlagDynamic | FlagAbandoned,
+	}
+
+	leases := Parse(bytes.NewBufferString(leaseData))
+	if len(leases) != len(want) {
+		t.Fatalf("found %d leases, expected %d", len(leases), len(want))
+	}
+	for i, l := range leases {
+		if got := l.Flags(); got != want[i] {
+			t.Errorf("%s has flags %b, expected %b", l.IP, got, want[i])
+		}
+	}
+
+	if leases[1].Flags()&FlagReserved == 0 {
+		t.Error("reserved lease should have FlagReserved set")
+	}
+}
+
+func TestIsReservation(t *testing.T) {
+	leaseData := `
+lease 10.0.0.5 {
+  binding state active;
+  reserved;
+}
+lease 10.0.0.6 {
+  binding state backup;
+  reserved;
+}
+lease 10.0.0.7 {
+  binding state backup;
+}
+lease 10.0.0.8 {
+  binding state active;
+}
+`
+	want := []bool{true, true, false, false}
+
+	leases := Parse(bytes.NewBufferString(leaseData))
+	if len(leases) != len(want) {
+		t.Fatalf("found %d leases, expected %d", len(leases), len(want))
+	}
+	for i, l := range leases {
+		if got := l.IsReservation(); got != want[i] {
+			t.Errorf("%s reservation is %v, expected %v", l.IP, got, want[i])
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package leases
+
+import (
+	"context"
+	log "github.com/sirupsen/logrus"
+	"os"
+	"time"
+)
+
+/*
+Follow reads the dhcpd.leases file at path like tail -f, sending each lease record on the returned
+channel as it is appended, starting with the records already in the file.  The file is polled
+every interval, using only its size and identity, so it works on network filesystems where change
+notifications are unreliable.  A lease part way through being written is sent once it is complete.
+If the file is replaced, such as by dhcpd rewriting it or logrotate moving it aside, or it shrinks,
+the new file is read from the start.
+
+Errors reading the file, such as while it is missing part way through a rotation, are sent on the
+error channel and polling carries on.  Both channels must be received from for Follow to make
+progress, and are closed once ctx is done.  Unlike Watch, every record is sent, not just changes
+to the current lease for each IP.
+*/
+func Follow(ctx context.Context, path string, interval time.Duration) (<-chan Lease, <-chan error) {
+	leases := make(chan Lease)
+	errs := make(chan error)
+
+	go func() {
+		defer close(errs)
+		defer close(leases)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		var file os.FileInfo
+		cur := &Cursor{}
+		for {
+			read, err := followPoll(path, &file, cur)
+			if err != nil {
+				log.WithFields(log.Fields{"path": path, "error": err}).Debug("Unable to read followed leases")
+				select {
+				case errs <- err:
+				case <-ctx.Done():
+					return
+				}
+			}
+			for _, l := range read {
+				select {
+				case leases <- l:
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return leases, errs
+}
+
+/*followPoll reads the leases appended to the file at path since cur, starting again if it has been replaced*/
+func followPoll(path string, file *os.FileInfo, cur *Cursor) ([]Lease, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if *file != nil && !os.SameFile(*file, info) {
+		cur.Offset = 0
+	}
+	if info.Size() == cur.Offset {
+		*file = info
+		return nil, nil
+	}
+	// file is only updated once cur has moved on in it, so a replacement that fails to read is read
+	// from the start again on the next poll rather than from the old file's offset
+	leases, err := ParseResume(f, cur)
+	if err == nil || len(leases) > 0 {
+		*file = info
+	}
+	return leases, err
+}
@@ -0,0 +1,70 @@
+package leases
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func nextLease(t *testing.T, leases <-chan Lease, errs <-chan error) Lease {
+	t.Helper()
+	select {
+	case l := <-leases:
+		return l
+	case err := <-errs:
+		t.Fatalf("unexpected error %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a lease")
+	}
+	return Lease{}
+}
+
+func TestFollow(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "dhcpd.leases")
+	if err := os.WriteFile(path, []byte("lease 10.0.0.5 {\n  binding state active;\n}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	leases, errs := Follow(ctx, path, 10*time.Millisecond)
+
+	if l := nextLease(t, leases, errs); l.IP.String() != "10.0.0.5" {
+		t.Errorf("expected the existing lease first, got %v", l)
+	}
+
+	// a lease written in two parts is only sent once it is complete
+	appendFile(t, path, "lease 10.0.0.5 {\n  binding state")
+	time.Sleep(50 * time.Millisecond)
+	appendFile(t, path, " free;\n}\n")
+	if l := nextLease(t, leases, errs); l.IP.String() != "10.0.0.5" || l.BindingState != "free" {
+		t.Errorf("expected the appended lease, got %v", l)
+	}
+
+	// a replacement file is read from the start
+	rotated := filepath.Join(dir, "dhcpd.leases.new")
+	if err := os.WriteFile(rotated, []byte("lease 10.0.0.6 {\n  binding state active;\n}\nlease 10.0.0.7 {\n  binding state active;\n}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(rotated, path); err != nil {
+		t.Fatal(err)
+	}
+	for _, ip := range []string{"10.0.0.6", "10.0.0.7"} {
+		if l := nextLease(t, leases, errs); l.IP.String() != ip {
+			t.Errorf("expected %s from the new file, got %v", ip, l)
+		}
+	}
+
+	cancel()
+	select {
+	case _, ok := <-leases:
+		if ok {
+			t.Error("expected no more leases after cancelling")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the lease channel to close")
+	}
+}
@@ -1,5 +1,7 @@
 module github.com/nijave/go-dhcpd-leases
 
-go 1.16
+go 1.18
 
-require github.com/sirupsen/logrus v1.8.1 // indirect
+require github.com/sirupsen/logrus v1.8.1
+
+require golang.org/x/sys v0.0.0-20191026070338-33540a1f6037 // indirect
@@ -0,0 +1,17 @@
+package leases
+
+import "strings"
+
+/*
+HostnameLabels splits the client hostname, with its escapes decoded, into its DNS labels, so
+"m8.example.com" gives "m8", "example" and "com".  The first label is the unqualified name and the
+rest the domain.  A trailing dot, as in a fully qualified "m8.example.com.", is ignored.  nil is
+returned if the lease has no hostname.
+*/
+func (l Lease) HostnameLabels() []string {
+	h := strings.TrimSuffix(string(unescape(l.ClientHostname)), ".")
+	if h == "" {
+		return nil
+	}
+	return strings.Split(h, ".")
+}
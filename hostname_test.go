@@ -0,0 +1,22 @@
+package leases
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHostnameLabels(t *testing.T) {
+	for hostname, want := range map[string][]string{
+		"m8":               {"m8"},
+		"m8.example.com":   {"m8", "example", "com"},
+		"m8.example.com.":  {"m8", "example", "com"},
+		`laptop\056lan`:    {"laptop", "lan"},
+		`quote\"d.example`: {`quote"d`, "example"},
+		"":                 nil,
+		".":                nil,
+	} {
+		if got := (Lease{ClientHostname: hostname}).HostnameLabels(); !reflect.DeepEqual(got, want) {
+			t.Errorf("labels of %q are %q, expected %q", hostname, got, want)
+		}
+	}
+}
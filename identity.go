@@ -0,0 +1,88 @@
+package leases
+
+import (
+	"bytes"
+	"encoding/hex"
+	"net"
+)
+
+/*
+UIDLen returns the number of bytes in the decoded client identifier, for telling identifier schemes
+apart without decoding them: 7 bytes is usually a hardware type of 1 followed by an ethernet MAC,
+and longer identifiers are usually DUID based.  0 is returned if the lease has no identifier.
+*/
+func (l Lease) UIDLen() int {
+	return len(l.UIDBytes)
+}
+
+/*
+SameHardware returns true if l and other were given to the same network interface, going by their MAC
+addresses, however they were written.  Leases without a valid MAC never match.
+*/
+func (l Lease) SameHardware(other Lease) bool {
+	return l.Hardware.MACAddr != nil && other.Hardware.MACAddr != nil && bytes.Equal(l.Hardware.MACAddr, other.Hardware.MACAddr)
+}
+
+/*
+Key returns the lease's IP in canonical form, the form CurrentByIP keys by, for keying maps of leases by
+address.  IPv4 addresses are in dotted decimal however they were written.  RawAddress is returned
+for leases without a valid IP.
+*/
+func (l Lease) Key() string {
+	if l.IP == nil {
+		return l.RawAddress
+	}
+	return l.IP.String()
+}
+
+/*
+ClientKey returns a canonical key for the client the lease was given to, for keying maps of leases
+by client.  It is "mac:" followed by the lower case, colon separated MAC from the hardware
+statement, or from a uid made up of hardware type 1 and an ethernet MAC if there is no hardware
+statement, so the two forms match.  Otherwise it is "uid:" followed by the uid in hexadecimal, or
+"" if the lease has neither.
+*/
+func (l Lease) ClientKey() string {
+	switch {
+	case l.Hardware.MACAddr != nil:
+		return "mac:" + l.Hardware.MACAddr.String()
+	case uidMAC(l.UIDBytes) != nil:
+		return "mac:" + uidMAC(l.UIDBytes).String()
+	case len(l.UIDBytes) > 0:
+		return "uid:" + hex.EncodeToString(l.UIDBytes)
+	}
+	return ""
+}
+
+/*
+HardwareUIDMatch returns whether the MAC embedded in the lease's client identifier matches the MAC
+in its hardware statement, for spotting spoofed or misconfigured clients.  applicable is false,
+and matches with it, unless the lease has both a valid MAC and a uid of hardware type 1 followed
+by an ethernet MAC, the form most clients send.  The MACs are compared as bytes, so the case and
+format they were written in don't matter.
+*/
+func (l Lease) HardwareUIDMatch() (matches, applicable bool) {
+	embedded := uidMAC(l.UIDBytes)
+	if embedded == nil || l.Hardware.MACAddr == nil {
+		return false, false
+	}
+	return bytes.Equal(embedded, l.Hardware.MACAddr), true
+}
+
+/*uidMAC returns the ethernet MAC in a client identifier of hardware type 1, or nil for any other identifier*/
+func uidMAC(uid []byte) net.HardwareAddr {
+	if len(uid) != 7 || uid[0] != 1 {
+		return nil
+	}
+	return net.HardwareAddr(uid[1:])
+}
+
+/*
+IsRandomizedMAC returns true if the lease's MAC is locally administered, the second least
+significant bit of its first octet set, as the randomized MACs of privacy features on phones and
+laptops are.  Such clients get a new MAC, and so a new lease, from time to time, inflating counts
+of clients by MAC.  false is returned for leases without a valid MAC.
+*/
+func (l Lease) IsRandomizedMAC() bool {
+	return len(l.Hardware.MACAddr) > 0 && l.Hardware.MACAddr[0]&0x02 != 0
+}
@@ -0,0 +1,157 @@
+package leases
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestUIDLen(t *testing.T) {
+	leaseData := `
+lease 172.16.0.60 {
+  uid "\001\000\333p\303\021\327";
+}
+lease 172.16.0.61 {
+  uid 1:0:db:70:c3:11:d7;
+}
+lease 172.16.0.62 {
+  uid "\377\000\000\000\001\000\001\036\215\352\363\000\014)\257\000\001";
+}
+lease 172.16.0.63 {
+  binding state free;
+}
+`
+	leases := Parse(bytes.NewBufferString(leaseData))
+	for i, want := range []int{7, 7, 17, 0} {
+		if got := leases[i].UIDLen(); got != want {
+			t.Errorf("lease %d uid length is %d, expected %d", i, got, want)
+		}
+	}
+}
+
+func TestSameHardware(t *testing.T) {
+	leaseData := `
+lease 172.16.0.60 {
+  hardware ethernet 00:db:70:c3:11:d7;
+}
+lease 172.16.0.61 {
+  hardware ethernet 00:DB:70:C3:11:D7;
+}
+lease 172.16.0.62 {
+  hardware ethernet 00:db:70:c3:11:d8;
+}
+lease 172.16.0.63 {
+  binding state free;
+}
+lease 172.16.0.64 {
+  hardware ethernet bogus;
+}
+`
+	leases := Parse(bytes.NewBufferString(leaseData))
+	for _, tc := range []struct {
+		a, b int
+		want bool
+	}{
+		{0, 1, true},
+		{0, 2, false},
+		{0, 3, false},
+		{3, 3, false},
+		{4, 4, false},
+	} {
+		if got := leases[tc.a].SameHardware(leases[tc.b]); got != tc.want {
+			t.Errorf("leases %d and %d same hardware is %v, expected %v", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
+
+func TestKeys(t *testing.T) {
+	leaseData := `
+lease 172.16.0.60 {
+  hardware ethernet 00:DB:70:C3:11:D7;
+  uid "\377\000\000\000\001";
+}
+lease 172.016.000.060x {
+  uid "\001\000\333p\303\021\327";
+}
+lease 2001:0db8:0000::0001 {
+  uid "\377\000\000\000\001";
+}
+lease ::ffff:172.16.0.61 {
+  binding state free;
+}
+`
+	leases := Parse(bytes.NewBufferString(leaseData))
+	for i, want := range []struct{ key, client string }{
+		{"172.16.0.60", "mac:00:db:70:c3:11:d7"},
+		{"172.016.000.060x", "mac:00:db:70:c3:11:d7"},
+		{"2001:db8::1", "uid:ff00000001"},
+		{"172.16.0.61", ""},
+	} {
+		if got := leases[i].Key(); got != want.key {
+			t.Errorf("lease %d key is %q, expected %q", i, got, want.key)
+		}
+		if got := leases[i].ClientKey(); got != want.client {
+			t.Errorf("lease %d client key is %q, expected %q", i, got, want.client)
+		}
+	}
+}
+
+func TestHardwareUIDMatch(t *testing.T) {
+	leaseData := `
+lease 172.16.0.60 {
+  hardware ethernet 00:DB:70:C3:11:D7;
+  uid "\001\000\333p\303\021\327";
+}
+lease 172.16.0.61 {
+  hardware ethernet 00:db:70:c3:11:d8;
+  uid 1:0:db:70:c3:11:d7;
+}
+lease 172.16.0.62 {
+  hardware ethernet 00:db:70:c3:11:d7;
+  uid "\377\000\000\000\001\000\001\036\215\352\363\000\014)\257\000\001";
+}
+lease 172.16.0.63 {
+  uid "\001\000\333p\303\021\327";
+}
+lease 172.16.0.64 {
+  hardware ethernet 00:db:70:c3:11:d7;
+}
+`
+	leases := Parse(bytes.NewBufferString(leaseData))
+	for i, want := range []struct{ matches, applicable bool }{
+		{true, true},
+		{false, true},
+		{false, false},
+		{false, false},
+		{false, false},
+	} {
+		if matches, applicable := leases[i].HardwareUIDMatch(); matches != want.matches || applicable != want.applicable {
+			t.Errorf("lease %d gave %v, %v, expected %v, %v", i, matches, applicable, want.matches, want.applicable)
+		}
+	}
+}
+
+func TestIsRandomizedMAC(t *testing.T) {
+	leaseData := `
+lease 172.16.0.60 {
+  hardware ethernet 00:db:70:c3:11:d7;
+}
+lease 172.16.0.61 {
+  hardware ethernet DA:A1:19:5E:33:07;
+}
+lease 172.16.0.62 {
+  hardware ethernet 02:00:00:00:00:01;
+}
+lease 172.16.0.63 {
+  hardware ethernet 01:00:5e:00:00:01;
+}
+lease 172.16.0.64 {
+  binding state free;
+}
+`
+	leases := Parse(bytes.NewBufferString(leaseData))
+	for i, want := range []bool{false, true, true, false, false} {
+		if got := leases[i].IsRandomizedMAC(); got != want {
+			t.Errorf("lease %d randomized is %v, expected %v", i, got, want)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package leases
+
+import (
+	"bytes"
+	"net"
+	"sort"
+	"strings"
+	"time"
+)
+
+/*
+CurrentByIP returns the current lease for each IP, keyed by Key, so leases without a valid IP are
+kept apart by the address as written.  dhcpd appends a new record each time a lease changes, so
+later leases in the slice replace earlier ones.
+*/
+func CurrentByIP(leases []Lease) map[string]Lease {
+	current := make(map[string]Lease, len(leases))
+	for _, l := range leases {
+		current[l.Key()] = l
+	}
+	return current
+}
+
+/*
+LatestByMAC returns the latest lease for each client, keyed by the normalised, lower case form of its
+MAC address, so the case a MAC was written in doesn't matter.  Leases without a valid MAC are
+skipped.
+*/
+func LatestByMAC(leases []Lease) map[string]Lease {
+	latest := map[string]Lease{}
+	for _, l := range leases {
+		if l.Hardware.MACAddr == nil {
+			continue
+		}
+		latest[l.Hardware.MACAddr.String()] = l
+	}
+	return latest
+}
+
+/*
+ActiveLeaseForMAC returns the latest lease for the client with the given MAC address if it is active
+at now.  mac may be in any form net.ParseMAC accepts, in either case.
+*/
+func ActiveLeaseForMAC(leases []Lease, mac string, now time.Time) (Lease, bool) {
+	m, err := net.ParseMAC(mac)
+	if err != nil {
+		return Lease{}, false
+	}
+	l, ok := LatestByMAC(leases)[m.String()]
+	if !ok || !l.IsActive(now) {
+		return Lease{}, false
+	}
+	return l, true
+}
+
+/*sortByIP sorts leases in place by IP address*/
+func sortByIP(leases []Lease) {
+	sort.SliceStable(leases, func(i, j int) bool {
+		return bytes.Compare(leases[i].IP.To16(), leases[j].IP.To16()) < 0
+	})
+}
+
+/*
+Hostnames returns the unique client hostnames of the current lease for each IP, sorted.  Earlier
+leases for an IP are ignored so stale hostnames don't appear, as are empty hostnames.
+*/
+func Hostnames(leases []Lease) []string {
+	seen := map[string]bool{}
+	var rtn []string
+	for _, l := range CurrentByIP(leases) {
+		h := strings.TrimSpace(l.ClientHostname)
+		if h == "" || seen[h] {
+			continue
+		}
+		seen[h] = true
+		rtn = append(rtn, h)
+	}
+	sort.Strings(rtn)
+	return rtn
+}
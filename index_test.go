@@ -0,0 +1,110 @@
+package leases
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestUppercaseMAC(t *testing.T) {
+	leaseData := `
+lease 172.16.0.60 {
+  starts 4 2022/03/31 15:52:00;
+  ends 4 2022/03/31 19:52:00;
+  binding state active;
+  hardware ethernet 00:DB:70:C3:11:D7;
+}
+lease 172.16.0.61 {
+  starts 4 2022/03/31 15:52:00;
+  ends 4 2022/03/31 19:52:00;
+  binding state active;
+  hardware ethernet 00:db:70:c3:11:d8;
+}
+`
+	leases := Parse(bytes.NewBufferString(leaseData))
+	if len(leases) != 2 {
+		t.Fatalf("found %d leases, expected 2", len(leases))
+	}
+	if mac := leases[0].Hardware.MACAddr.String(); mac != "00:db:70:c3:11:d7" {
+		t.Errorf("MAC %s should be normalised to lower case", mac)
+	}
+	if leases[0].Hardware.MAC != "00:DB:70:C3:11:D7" {
+		t.Errorf("raw MAC %s should be kept as written", leases[0].Hardware.MAC)
+	}
+
+	byMAC := LatestByMAC(leases)
+	if l, ok := byMAC["00:db:70:c3:11:d7"]; !ok || l.IP.String() != "172.16.0.60" {
+		t.Errorf("lower case key should find 172.16.0.60, got %v", byMAC)
+	}
+
+	now := time.Date(2022, 3, 31, 16, 0, 0, 0, time.UTC)
+	for _, mac := range []string{"00:db:70:c3:11:d7", "00:DB:70:C3:11:D7", "00-db-70-c3-11-d7"} {
+		if l, ok := ActiveLeaseForMAC(leases, mac, now); !ok || l.IP.String() != "172.16.0.60" {
+			t.Errorf("%s should find 172.16.0.60, got %v, %v", mac, l.IP, ok)
+		}
+	}
+	if _, ok := ActiveLeaseForMAC(leases, "00:DB:70:C3:11:D8", now.Add(24*time.Hour)); ok {
+		t.Error("expired lease shouldn't be active")
+	}
+	if _, ok := ActiveLeaseForMAC(leases, "not a mac", now); ok {
+		t.Error("invalid MAC shouldn't match")
+	}
+}
+
+func TestHostnames(t *testing.T) {
+	leaseData := `
+lease 172.16.0.60 {
+  client-hostname "stale";
+}
+lease 172.16.0.61 {
+  client-hostname "m8";
+}
+lease 172.16.0.62 {
+  client-hostname "  alpha ";
+}
+lease 172.16.0.63 {
+  client-hostname "m8";
+}
+lease 172.16.0.64 {
+  binding state free;
+}
+lease 172.16.0.60 {
+  client-hostname "zulu";
+}
+`
+	got := Hostnames(Parse(bytes.NewBufferString(leaseData)))
+	want := []string{"alpha", "m8", "zulu"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("hostnames are %q, expected %q", got, want)
+	}
+}
+
+func TestCurrentByIPInvalidAddresses(t *testing.T) {
+	leaseData := `
+lease printer.example.com {
+  binding state active;
+}
+lease 10.0.0.300 {
+  binding state active;
+}
+lease 10.0.0.5 {
+  binding state active;
+}
+`
+	leases := Parse(bytes.NewBufferString(leaseData))
+	current, err := ParseCurrent(bytes.NewBufferString(leaseData), ParseOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	for _, m := range []map[string]Lease{CurrentByIP(leases), current} {
+		if len(m) != 3 {
+			t.Errorf("found %d current leases, expected 3: %v", len(m), m)
+		}
+		for _, key := range []string{"printer.example.com", "10.0.0.300", "10.0.0.5"} {
+			if _, ok := m[key]; !ok {
+				t.Errorf("expected a lease for %s in %v", key, m)
+			}
+		}
+	}
+}
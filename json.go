@@ -0,0 +1,75 @@
+package leases
+
+import (
+	"encoding/json"
+	"io"
+)
+
+/*
+jsonLease is the form of a Lease StreamJSON writes.  The timestamps shadow the Lease's own, as
+encoding/json can't encode the Never time, and are written as for ToMap, omitted when missing
+*/
+type jsonLease struct {
+	Lease
+	Starts string `json:"starts,omitempty"`
+	Ends   string `json:"ends,omitempty"`
+	Tstp   string `json:"tstp,omitempty"`
+	Tsfp   string `json:"tsfp,omitempty"`
+	Atsfp  string `json:"atsfp,omitempty"`
+	Cltt   string `json:"cllt,omitempty"`
+
+	//MAC is the normalised, lower case form of Hardware.MAC
+	MAC string `json:"mac,omitempty"`
+}
+
+/*stopReader reads from r until *err is set, then returns it, to stop a parse part way through*/
+type stopReader struct {
+	r   io.Reader
+	err *error
+}
+
+func (s stopReader) Read(p []byte) (int, error) {
+	if *s.err != nil {
+		return 0, *s.err
+	}
+	return s.r.Read(p)
+}
+
+/*
+StreamJSON parses leases from r and writes each to w as a line of JSON as soon as it is parsed, for
+piping huge lease files into tools that read newline delimited JSON without holding every lease in
+memory.  Each line has the Lease's fields plus mac, the normalised MAC.  Timestamps are RFC 3339 in
+UTC, or "never", and are left out when missing.  w is flushed after each lease if it has a Flush
+method.  Parse errors are returned as for ParseWithOptions; an error writing to w stops the parse
+and is returned.
+*/
+func StreamJSON(r io.Reader, w io.Writer) error {
+	var werr error
+	enc := json.NewEncoder(w)
+	flusher, _ := w.(interface{ Flush() error })
+
+	err := parseLeases(stopReader{r: r, err: &werr}, ParseOptions{}, func(l Lease) {
+		if werr != nil {
+			return
+		}
+		jl := jsonLease{
+			Lease:  l,
+			Starts: formatMapTime(l.Starts),
+			Ends:   formatMapTime(l.Ends),
+			Tstp:   formatMapTime(l.Tstp),
+			Tsfp:   formatMapTime(l.Tsfp),
+			Atsfp:  formatMapTime(l.Atsfp),
+			Cltt:   formatMapTime(l.Cltt),
+		}
+		if l.Hardware.MACAddr != nil {
+			jl.MAC = l.Hardware.MACAddr.String()
+		}
+		if werr = enc.Encode(jl); werr == nil && flusher != nil {
+			werr = flusher.Flush()
+		}
+	})
+	if werr != nil {
+		return werr
+	}
+	return err
+}
@@ -0,0 +1,84 @@
+package leases
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+/*failingWriter fails every write after the first n*/
+type failingWriter struct {
+	n      int
+	writes int
+}
+
+var errWrite = errors.New("write failed")
+
+func (f *failingWriter) Write(p []byte) (int, error) {
+	if f.writes >= f.n {
+		return 0, errWrite
+	}
+	f.writes++
+	return len(p), nil
+}
+
+func TestStreamJSON(t *testing.T) {
+	leaseData := `
+lease 172.16.0.60 {
+  starts 6 2019/04/27 03:24:45;
+  ends never;
+  binding state active;
+  hardware ethernet 00:DB:70:C3:11:D7;
+  client-hostname "m8";
+}
+lease 172.16.0.61 {
+  binding state free;
+}
+`
+	var out bytes.Buffer
+	bw := bufio.NewWriter(&out)
+	if err := StreamJSON(bytes.NewBufferString(leaseData), bw); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	// every lease is flushed as it is written, leaving nothing buffered
+	if bw.Buffered() != 0 {
+		t.Errorf("%d bytes left unflushed", bw.Buffered())
+	}
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("found %d lines, expected 2: %q", len(lines), out.String())
+	}
+	var m map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &m); err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]any{
+		"ip":              "172.16.0.60",
+		"starts":          "2019-04-27T03:24:45Z",
+		"ends":            "never",
+		"binding-state":   "active",
+		"mac":             "00:db:70:c3:11:d7",
+		"client-hostname": "m8",
+	} {
+		if m[key] != want {
+			t.Errorf("%s is %v, expected %v", key, m[key], want)
+		}
+	}
+	if _, ok := m["tstp"]; ok {
+		t.Error("expected the missing tstp to be left out")
+	}
+	if hw, _ := m["hardware"].(map[string]any); hw["mac"] != "00:DB:70:C3:11:D7" {
+		t.Errorf("expected the MAC as written in hardware, got %v", m["hardware"])
+	}
+
+	if err := StreamJSON(bytes.NewBufferString(leaseData), &failingWriter{n: 1}); err != errWrite {
+		t.Errorf("expected the write error, got %v", err)
+	}
+	if err := StreamJSON(bytes.NewBufferString("lease 172.16.0.62 {\n"), &out); err != ErrTruncated {
+		t.Errorf("expected ErrTruncated, got %v", err)
+	}
+}
@@ -1,11 +1,12 @@
 package leases
 
 import (
-	"bufio"
 	"bytes"
-	"encoding/hex"
+	"errors"
+	"fmt"
 	log "github.com/sirupsen/logrus"
 	"net"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -26,12 +27,14 @@ A Lease contains the data specified in the following format:
 		hardware ethernet 00:db:70:c3:11:d7;
 		uid "\001\000\333p\303\021\327";
 	}
-
 */
 type Lease struct {
 	//IP address given to the lease
 	IP net.IP `json:"ip"`
 
+	//RawAddress is the address as written in the lease statement, kept in case it isn't a valid IP
+	RawAddress string `json:"raw-address"`
+
 	//Start time of the lease
 	Starts time.Time `json:"starts"`
 
@@ -50,6 +53,14 @@ type Lease struct {
 	//Cltt is the client's last transaction time
 	Cltt time.Time `json:"cllt"`
 
+	//StartsRaw through ClttRaw are the timestamps as written, such as "6 2019/04/27 03:24:45", for checking them against the parsed times. Only populated when ParseOptions.KeepRawTimes is set
+	StartsRaw string `json:"starts-raw,omitempty"`
+	EndsRaw   string `json:"ends-raw,omitempty"`
+	TstpRaw   string `json:"tstp-raw,omitempty"`
+	TsfpRaw   string `json:"tsfp-raw,omitempty"`
+	AtsfpRaw  string `json:"atsfp-raw,omitempty"`
+	ClttRaw   string `json:"cltt-raw,omitempty"`
+
 	/*The binding state statement declares the lease's binding state. When the DHCP server is
 	not configured to use the failover protocol, a lease's binding state will be either
 	active or free. The failover protocol adds some additional transitional states, as
@@ -60,6 +71,7 @@ type Lease struct {
 	//The next binding state statement indicates what state the lease will move to when the current state expires. The time when the current state expires is specified in the ends statement.
 	NextBindingState string `json:"next-binding-state"`
 
+	//RewindBindingState is recorded by servers using the failover protocol. It is the binding state the failover partner last acknowledged, which the lease is rewound to if the partner never received a later change. Once the partner acknowledges the current state it matches BindingState
 	RewindBindingState string `json:"rewind-binding-state"`
 
 	//The hardware statement records the MAC address of the network interface on which the lease will be used. It is specified as a series of hexadecimal octets, separated by colons.
@@ -72,13 +84,84 @@ type Lease struct {
 	//The uid statement records the client identifier used by the client to acquire the lease. Clients are not required to send client identifiers, and this statement only appears if the client did in fact send one. Client identifiers are normally an ARP type (1 for ethernet) followed by the MAC address, just like in the hardware statement, but this is not required.
 	UID string `json:"uid"`
 
+	//UIDBytes is the client identifier from UID with its octal escapes or hexadecimal list decoded
+	UIDBytes []byte `json:"-"`
+
 	//Clients provided hostname
 	ClientHostname string `json:"client-hostname"`
+
+	//OptionHostName is the host-name option recorded with the lease, which can differ from ClientHostname. It is also kept in Options with its quotes
+	OptionHostName string `json:"option-host-name,omitempty"`
+
+	//Reserved is set for leases dhcpd keeps for a particular client, so they are never allocated dynamically to another
+	Reserved bool `json:"reserved,omitempty"`
+
+	//Bootp is set for leases allocated to BOOTP clients
+	Bootp bool `json:"bootp,omitempty"`
+
+	//PreferredLifetime and MaxLifetime are the preferred-life and max-life recorded for DHCPv6 addresses. They are zero for DHCPv4 leases
+	PreferredLifetime time.Duration `json:"preferred-life,omitempty"`
+	MaxLifetime       time.Duration `json:"max-life,omitempty"`
+
+	//Options holds the option statements recorded with the lease, keyed by option name. Values are the raw text following the name, so quoted values keep their quotes
+	Options map[string]string `json:"options,omitempty"`
+
+	//Assignments holds statements of the form "name = value;" that have no decoder of their own, keyed by name. Values are the raw text after the '=', as for Options. set statements aren't included
+	Assignments map[string]string `json:"assignments,omitempty"`
+
+	//Unknown holds the statements without a decoder, such as the fields of extended formats, keyed by their first word with the rest of the statement as the value. Nothing isc-dhcp writes ends up here. Unknown statements aren't written back by Write
+	Unknown map[string]string `json:"unknown,omitempty"`
+
+	//Comments holds the '#' comments written in the lease block, in order, with the '#' and surrounding space trimmed. Only populated when ParseOptions.KeepComments is set
+	Comments []string `json:"comments,omitempty"`
+
+	//Errors holds a MalformedLeaseError for each of the lease's statements that couldn't be decoded. Statements with errors are otherwise skipped
+	Errors []error `json:"-"`
+
+	//Source is the file the lease was read from. Only populated by ParseFileTagged
+	Source string `json:"source,omitempty"`
+
+	//Subnet is the subnet declaration the record was found in. Only populated by ParseConfig
+	Subnet *net.IPNet `json:"-"`
+
+	//Host is the name of the host declaration a reservation was read from. Only populated by ParseConfig
+	Host string `json:"host,omitempty"`
+
+	//Partial is set for a lease recovered from a block cut off by the end of the input. Only populated when ParseOptions.RecoverPartial is set
+	Partial bool `json:"partial,omitempty"`
+
+	//Offset is the position in bytes of the lease block in the parsed stream. Only populated when ParseOptions.Offsets is set
+	Offset int64 `json:"offset,omitempty"`
+
+	//Length is the size in bytes of the lease block, from the lease keyword through the closing brace. Only populated when ParseOptions.Offsets is set
+	Length int `json:"length,omitempty"`
+
+	//assumedState is set when BindingState was filled in by ParseOptions.AssumeActive
+	assumedState bool
 }
 
 var (
+	//Never is the time recorded for timestamps written as "never"
+	Never = time.Unix(1<<63-62135596801, 999999999)
+
 	stringDecoders = map[string]func(*Lease, string){
-		"lease ":  func(l *Lease, line string) { l.IP = net.ParseIP(parseKeyword(line, 1)) },
+		"lease ": func(l *Lease, line string) {
+			// lease 10.0.0.5 { # the address is the only token wanted, whatever follows it
+			if i := strings.IndexByte(line, '#'); i != -1 {
+				line = line[:i]
+			}
+			addr := ""
+			if f := strings.Fields(line); len(f) > 1 {
+				addr = strings.TrimSuffix(f[1], "{")
+			}
+			if l.RawAddress != "" {
+				// a corrupt file can have another lease inside this one, keep the outer lease's address
+				l.addError(line, fmt.Errorf("%w for %s inside lease for %s", ErrNestedLease, addr, l.RawAddress))
+				return
+			}
+			l.RawAddress = addr
+			l.IP = net.ParseIP(l.RawAddress)
+		},
 		"cltt ":   func(l *Lease, line string) { l.Cltt = parseTime(line) },
 		"starts ": func(l *Lease, line string) { l.Starts = parseTime(line) },
 		"ends ":   func(l *Lease, line string) { l.Ends = parseTime(line) },
@@ -87,8 +170,8 @@ var (
 		"atsfp ":  func(l *Lease, line string) { l.Atsfp = parseTime(line) },
 		"uid ": func(l *Lease, line string) {
 			if strings.HasPrefix(line, "uid \"") {
-				// TODO maybe octal representation should be unencoded before storing to string
 				l.UID = parseQuoted(line)
+				l.UIDBytes = unescape(l.UID)
 			} else {
 				// Alternate form I think...
 
@@ -97,11 +180,13 @@ var (
 				// and it contains one or more non-printable characters, those
 				// characters are represented as octal escapes - a backslash character
 				// followed by three octal digits.
-				bytes, err := hex.DecodeString(strings.Replace(parseKeyword(line, 2), ":", "", -1))
+				l.UID = parseKeyword(line, 1)
+				bytes, err := parseHexList(l.UID)
 				if err != nil {
+					l.addError(line, fmt.Errorf("decoding uid %q: %w", l.UID, err))
 					return
 				}
-				l.UID = string(bytes)
+				l.UIDBytes = bytes
 			}
 		},
 		"client-hostname ":      func(l *Lease, line string) { l.ClientHostname = parseQuoted(line) },
@@ -109,11 +194,39 @@ var (
 		"next binding state ":   func(l *Lease, line string) { l.NextBindingState = parseKeyword(line, 3) },
 		"rewind binding state ": func(l *Lease, line string) { l.RewindBindingState = parseKeyword(line, 3) },
 		"hardware ": func(l *Lease, line string) {
-			s := strings.SplitN(line, " ", 2)
-			l.Hardware.Hardware = s[0]
-			l.Hardware.MAC = s[1]
-			if m, e := net.ParseMAC(s[1]); e == nil {
-				l.Hardware.MACAddr = m
+			// hardware ethernet 00:db:70:c3:11:d7;
+			s := strings.SplitN(strings.TrimRight(line, ";"), " ", 3)
+			if len(s) < 3 || s[2] == "" {
+				// a corrupt "hardware ethernet;" has no address, keep the type alone
+				if len(s) > 1 {
+					l.Hardware.Hardware = s[1]
+				}
+				l.addError(line, errors.New("hardware statement without an address"))
+				return
+			}
+			l.Hardware.Hardware = s[1]
+			l.Hardware.MAC = s[2]
+			l.Hardware.MACAddr = parseHardwareAddr(s[2])
+		},
+		"reserved;": func(l *Lease, line string) { l.Reserved = true },
+		"bootp;":    func(l *Lease, line string) { l.Bootp = true },
+		// OpenBSD and old versions of ISC dhcpd write these rather than bootp; and binding state abandoned;
+		"dynamic-bootp;":  func(l *Lease, line string) { l.Bootp = true },
+		"abandoned;":      func(l *Lease, line string) { l.BindingState = "abandoned" },
+		"preferred-life ": func(l *Lease, line string) { l.PreferredLifetime = parseSeconds(l, line) },
+		"max-life ":       func(l *Lease, line string) { l.MaxLifetime = parseSeconds(l, line) },
+		"option ": func(l *Lease, line string) {
+			// option routers 10.0.0.1;
+			s := strings.SplitN(strings.TrimRight(line, ";"), " ", 3)
+			if len(s) < 3 {
+				return
+			}
+			if l.Options == nil {
+				l.Options = map[string]string{}
+			}
+			l.Options[s[1]] = s[2]
+			if s[1] == "host-name" {
+				l.OptionHostName = parseQuoted(line[len("option "):])
 			}
 		},
 		// TODO?
@@ -121,39 +234,339 @@ var (
 	}
 )
 
-/*parseTime from the off format of "6 2019/04/27 03:34:45;" adn returns a time struct*/
+/*
+parseAssignment decodes a statement of the form "name = value;" into Assignments, returning false
+if line isn't one.  set statements have their own decoder and aren't assignments
+*/
+func (l *Lease) parseAssignment(line string) bool {
+	name, value, ok := strings.Cut(line, " = ")
+	if !ok || name == "" || strings.ContainsAny(name, " \"") {
+		return false
+	}
+	if l.Assignments == nil {
+		l.Assignments = map[string]string{}
+	}
+	l.Assignments[name] = strings.TrimRight(value, "; ")
+	return true
+}
+
+/*addUnknown records a statement without a decoder in Unknown*/
+func (l *Lease) addUnknown(line string) {
+	name, value, _ := strings.Cut(strings.TrimRight(line, "; "), " ")
+	if l.Unknown == nil {
+		l.Unknown = map[string]string{}
+	}
+	l.Unknown[name] = value
+}
+
+/*rawTimes returns where to keep the raw form of each timestamp statement*/
+func (l *Lease) rawTimes() map[string]*string {
+	return map[string]*string{
+		"starts ": &l.StartsRaw,
+		"ends ":   &l.EndsRaw,
+		"tstp ":   &l.TstpRaw,
+		"tsfp ":   &l.TsfpRaw,
+		"atsfp ":  &l.AtsfpRaw,
+		"cltt ":   &l.ClttRaw,
+	}
+}
+
+/*normalizeCase lower cases the fields ParseOptions.NormalizeCase covers*/
+func (l *Lease) normalizeCase() {
+	l.Hardware.MAC = strings.ToLower(l.Hardware.MAC)
+	l.ClientHostname = strings.ToLower(l.ClientHostname)
+	l.OptionHostName = strings.ToLower(l.OptionHostName)
+	l.BindingState = strings.ToLower(l.BindingState)
+	l.NextBindingState = strings.ToLower(l.NextBindingState)
+	l.RewindBindingState = strings.ToLower(l.RewindBindingState)
+	if l.Options != nil {
+		options := make(map[string]string, len(l.Options))
+		for k, v := range l.Options {
+			options[strings.ToLower(k)] = v
+		}
+		l.Options = options
+	}
+}
+
+/*addError records a problem decoding line as a MalformedLeaseError*/
+func (l *Lease) addError(line string, err error) {
+	log.WithFields(log.Fields{"line": line, "error": err}).Warn("Unable to decode lease statement")
+	l.Errors = append(l.Errors, &MalformedLeaseError{Line: line, Err: err})
+}
+
+/*parseSeconds parses a statement such as "max-life 600;" into a duration*/
+func parseSeconds(l *Lease, line string) time.Duration {
+	s, err := strconv.Atoi(parseKeyword(line, 1))
+	if err != nil {
+		l.addError(line, fmt.Errorf("decoding seconds: %w", err))
+		return 0
+	}
+	return time.Duration(s) * time.Second
+}
+
+/*parseTime from the off format of "starts 6 2019/04/27 03:34:45;" and returns a time struct*/
 func parseTime(s string) time.Time {
-	s = strings.TrimRight(s, ";")
+	if i := strings.Index(s, " "); i != -1 {
+		return parseDate(timeValue(s[i+1:]))
+	}
+	return time.Time{}
+}
 
-	if strings.HasSuffix(s, " never") {
-		return time.Unix(1<<63-62135596801, 999999999)
+/*
+timeValue returns the value of a timestamp statement without the trailing ';' and the comment
+dhcpd writes after the epoch form, "epoch 1556335485; # Sat Apr 27 03:24:45 2019"
+*/
+func timeValue(s string) string {
+	if i := strings.IndexByte(s, '#'); i != -1 {
+		s = strings.TrimRight(s[:i], " ")
 	}
+	return strings.TrimRight(s, ";")
+}
 
-	s = strings.SplitN(s, " ", 3)[2]
-	t, _ := time.Parse("2006/01/02 15:04:05", s)
+/*
+parseDate parses the date portion of a timestamp statement, "6 2019/04/27 03:34:45", "never" or
+the "epoch 1556335485" form of seconds since the epoch newer versions of dhcpd can write.  The
+leading weekday is optional, "2019/04/27 03:34:45" is parsed the same way.
+*/
+func parseDate(s string) time.Time {
+	if s == "never" || strings.HasSuffix(s, " never") {
+		return Never
+	}
+	if strings.HasPrefix(s, "epoch ") {
+		secs, err := strconv.ParseInt(s[len("epoch "):], 10, 64)
+		if err != nil {
+			return time.Time{}
+		}
+		return time.Unix(secs, 0).UTC()
+	}
 
-	log.WithFields(log.Fields{"inputString": s, "time": t}).Trace("Parsed timestamp")
+	if len(s) > 2 && s[0] >= '0' && s[0] <= '6' && s[1] == ' ' {
+		s = s[2:]
+	}
+	t, ok := parseFixedDate(s)
+	if !ok {
+		// time.Parse accepts fractional seconds after the seconds field even though the layout has none,
+		// so timestamps written with sub-second precision keep it
+		t, _ = time.Parse("2006/01/02 15:04:05", s)
+	}
+
+	if log.IsLevelEnabled(log.TraceLevel) {
+		log.WithFields(log.Fields{"inputString": s, "time": t}).Trace("Parsed timestamp")
+	}
 	return t
 }
 
+/*
+parseFixedDate parses the "2019/04/27 03:34:45" form dhcpd normally writes without going through
+time.Parse, which is much slower and allocates.  ok is false for anything else, including
+fractional seconds and out of range fields, which are left to time.Parse.
+*/
+func parseFixedDate(s string) (t time.Time, ok bool) {
+	if len(s) != 19 || s[4] != '/' || s[7] != '/' || s[10] != ' ' || s[13] != ':' || s[16] != ':' {
+		return time.Time{}, false
+	}
+	year, ok1 := atoiFixed(s[0:4])
+	month, ok2 := atoiFixed(s[5:7])
+	day, ok3 := atoiFixed(s[8:10])
+	hour, ok4 := atoiFixed(s[11:13])
+	minute, ok5 := atoiFixed(s[14:16])
+	sec, ok6 := atoiFixed(s[17:19])
+	if !(ok1 && ok2 && ok3 && ok4 && ok5 && ok6) {
+		return time.Time{}, false
+	}
+	if month < 1 || month > 12 || day < 1 || day > daysIn(time.Month(month), year) ||
+		hour > 23 || minute > 59 || sec > 59 {
+		return time.Time{}, false
+	}
+	return time.Date(year, time.Month(month), day, hour, minute, sec, 0, time.UTC), true
+}
+
+/*atoiFixed parses a string made up only of decimal digits*/
+func atoiFixed(s string) (int, bool) {
+	n := 0
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return 0, false
+		}
+		n = n*10 + int(s[i]-'0')
+	}
+	return n, true
+}
+
+/*daysIn returns the number of days in month m of year*/
+func daysIn(m time.Month, year int) int {
+	// day 0 of the following month is the last day of m
+	return time.Date(year, m+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+/*
+parseQuoted returns the value of a statement such as `client-hostname "my host";`, the whole quoted
+string with its escapes as written.  Statements without quotes give the text after the keyword
+*/
 func parseQuoted(s string) string {
-	sParsed := strings.TrimRight(s, ";")
-	sParsed = strings.SplitN(sParsed, " ", 2)[1]
-	sParsed = strings.Trim(sParsed, "\"")
+	sParsed, ok := quotedValue(s)
+	if !ok {
+		sParsed = strings.TrimRight(s, ";")
+		if _, v, found := strings.Cut(sParsed, " "); found {
+			sParsed = v
+		}
+	}
 
 	log.WithFields(log.Fields{"inputString": s, "string": sParsed}).Trace("Parsed quoted string")
 	return sParsed
 }
 
+/*
+quotedValue returns the text between the first quote in s and the quote closing it, skipping over
+escaped quotes, so spaces, semicolons and quotes inside the string are kept.  false is returned if
+s has no complete quoted string
+*/
+func quotedValue(s string) (string, bool) {
+	start := strings.IndexByte(s, '"')
+	if start == -1 {
+		return "", false
+	}
+	for i := start + 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return s[start+1 : i], true
+		}
+	}
+	return "", false
+}
+
 func parseKeyword(s string, location int) string {
-	sParsed := strings.TrimRight(s, ";")
-	sParsed = strings.Split(sParsed, " ")[location]
+	// a comment after the statement, such as "binding state active; # renewed", isn't part of it
+	if i := commentIndex(s); i != -1 {
+		s = s[:i]
+	}
+	sParsed := strings.TrimRight(s, "; ")
+	// Fields rather than splitting on single spaces, so irregular spacing doesn't shift the tokens
+	fields := strings.Fields(sParsed)
+	if location >= len(fields) {
+		return ""
+	}
+	sParsed = fields[location]
 
 	log.WithFields(log.Fields{"inputString": s, "location": location, "string": sParsed}).Trace("Parsed keyword")
 	return sParsed
 }
 
-/*parse takes a byte slice that looks like:
+/*
+collapseSpaces replaces runs of spaces outside quotes with a single space and drops spaces before
+the ';' ending a statement, so "binding  state   free ;" is decoded like "binding state free;"
+*/
+func collapseSpaces(line string) string {
+	if !strings.Contains(line, "  ") && !strings.Contains(line, " ;") {
+		return line
+	}
+
+	var sb strings.Builder
+	inQuotes := false
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case inQuotes && c == '\\' && i+1 < len(line):
+			sb.WriteByte(c)
+			i++
+			c = line[i]
+		case c == '"':
+			inQuotes = !inQuotes
+		case !inQuotes && c == ' ':
+			if i+1 < len(line) && (line[i+1] == ' ' || line[i+1] == ';') {
+				continue
+			}
+		}
+		sb.WriteByte(c)
+	}
+	return sb.String()
+}
+
+/*
+lenientLine returns line with tabs outside quotes turned into spaces, any '#' comment outside quotes
+removed and the leading and trailing space trimmed, for ParseOptions.Lenient
+*/
+func lenientLine(line string) string {
+	if !strings.ContainsAny(line, "\t#") {
+		return strings.Trim(line, " ")
+	}
+
+	var sb strings.Builder
+	inQuotes := false
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case inQuotes && c == '\\' && i+1 < len(line):
+			sb.WriteByte(c)
+			i++
+			c = line[i]
+		case c == '"':
+			inQuotes = !inQuotes
+		case !inQuotes && c == '\t':
+			c = ' '
+		case !inQuotes && c == '#':
+			return strings.Trim(sb.String(), " ")
+		}
+		sb.WriteByte(c)
+	}
+	return strings.Trim(sb.String(), " ")
+}
+
+/*
+parseHardwareAddr returns the address in a hardware statement, or nil if it isn't valid.  As well
+as the forms net.ParseMAC accepts, octets may be written without their leading zero, as in
+0:0:0:0:0:a.
+*/
+func parseHardwareAddr(s string) net.HardwareAddr {
+	if m, err := net.ParseMAC(s); err == nil {
+		return m
+	}
+	b, err := parseHexList(s)
+	if err != nil {
+		return nil
+	}
+	switch len(b) {
+	case 6, 8, 20:
+		return net.HardwareAddr(b)
+	}
+	return nil
+}
+
+/*commentIndex returns the index of the '#' starting a comment outside quotes in line, or -1 if there isn't one*/
+func commentIndex(line string) int {
+	if strings.IndexByte(line, '#') == -1 {
+		return -1
+	}
+	inQuotes := false
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case inQuotes && c == '\\':
+			i++
+		case c == '"':
+			inQuotes = !inQuotes
+		case !inQuotes && c == '#':
+			return i
+		}
+	}
+	return -1
+}
+
+/*
+nextLine returns the first line of s, without its line ending, and the rest of s.  Blocks are
+already in memory, so they are split into lines directly rather than with a bufio.Scanner, which
+would drop statements longer than its buffer.
+*/
+func nextLine(s []byte) (string, []byte) {
+	if i := bytes.IndexByte(s, '\n'); i != -1 {
+		return string(bytes.TrimSuffix(s[:i], []byte("\r"))), s[i+1:]
+	}
+	return string(bytes.TrimSuffix(s, []byte("\r"))), nil
+}
+
+/*
+parse takes a byte slice that looks like:
 
 	172.24.43.3 {
 		starts 6 2019/04/27 03:24:45;
@@ -165,17 +578,50 @@ func parseKeyword(s string, location int) string {
 		uid "\001\000\333p\303\021\327";
 	}
 
-And populates the value of l with the values recoded
+And populates the value of l with the values recoded.  Lines without a decoder are passed to
+opts.OnUnknown
 */
-func (l *Lease) parse(s []byte) {
+func (l *Lease) parse(s []byte, opts *ParseOptions) {
 	log.WithField("leaseToken", s).Trace("Parsing lease token")
-	buf := bytes.NewBuffer(s)
-	scanner := bufio.NewScanner(buf)
-	scanner.Split(bufio.ScanLines)
-	for scanner.Scan() {
-		line := scanner.Text()
-		line = strings.TrimLeft(line, " ")
+	// nested is the depth of a lease block written inside this one, whose statements are skipped
+	nested := 0
+	for len(s) > 0 {
+		var line string
+		line, s = nextLine(s)
+		// comments are cut from the line before any decoder sees it, and a line holding only a
+		// comment isn't a statement, whether or not the comment is kept
+		comment := false
+		if i := commentIndex(line); i != -1 {
+			if opts.KeepComments {
+				l.Comments = append(l.Comments, strings.TrimSpace(line[i+1:]))
+			}
+			line = strings.TrimRight(line[:i], " \t")
+			comment = strings.TrimSpace(line) == ""
+		}
+		switch {
+		case opts.Lenient:
+			line = lenientLine(line)
+		case opts.Dialect == DialectOpenBSD:
+			line = strings.TrimLeft(line, " \t")
+		default:
+			line = strings.TrimLeft(line, " ")
+		}
+		line = collapseSpaces(line)
+		braces := strings.Count(line, "{") - strings.Count(line, "}")
+		if nested > 0 {
+			nested += braces
+			continue
+		}
+		if l.RawAddress != "" && strings.HasPrefix(line, "lease ") {
+			// the lease decoder reports the nested lease, the statements in its block are skipped
+			nested = braces
+		}
+		// compact writers may put the closing brace on the same line as the last statement
+		if line != "}" && strings.HasSuffix(line, "}") {
+			line = strings.TrimRight(strings.TrimSuffix(line, "}"), " ")
+		}
 
+		known := false
 		for prefix, parser := range stringDecoders {
 			if strings.HasPrefix(line, prefix) {
 				log.WithFields(log.Fields{
@@ -184,6 +630,26 @@ func (l *Lease) parse(s []byte) {
 					"lease":  l,
 				}).Trace("Decoding line")
 				parser(l, line)
+				known = true
+			}
+		}
+		if !known {
+			known = l.parseAssignment(line)
+		}
+		if known && opts.KeepRawTimes {
+			for prefix, raw := range l.rawTimes() {
+				if strings.HasPrefix(line, prefix) {
+					*raw = timeValue(line[len(prefix):])
+				}
+			}
+		}
+		if !known && !comment && line != "" && line != "}" {
+			l.addUnknown(line)
+			if opts.Stats != nil {
+				opts.Stats.Unknown++
+			}
+			if opts.OnUnknown != nil {
+				opts.OnUnknown(l, line)
 			}
 		}
 	}
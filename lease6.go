@@ -0,0 +1,130 @@
+package leases
+
+import (
+	"bytes"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+var (
+	mixedStartKeywords = [][]byte{
+		[]byte("\nlease "),
+		[]byte("\nia-na "),
+		[]byte("\nia-ta "),
+		[]byte("\nia-pd "),
+	}
+)
+
+/*
+Lease6 is an identity association recorded by the DHCPv6 server, holding the addresses or prefixes
+leased to a client:
+
+	ia-na "\001\000\000\000\000\001\000\001\036\215\352\363\000\014)\257\000\001" {
+		cltt 2 2020/01/07 10:00:00;
+		iaaddr 2001:db8::100 {
+			binding state active;
+			preferred-life 375;
+			max-life 600;
+			ends 2 2020/01/07 10:10:00;
+		}
+	}
+*/
+type Lease6 struct {
+	//Type of identity association, one of ia-na, ia-ta or ia-pd
+	Type string `json:"type"`
+
+	//ID is the identity association's quoted identifier, the IAID followed by the client's DUID
+	ID string `json:"id"`
+
+	//IAID and DUID are the two parts of ID decoded. IAID is as written, in the byte order of the server that wrote the file
+	IAID []byte `json:"-"`
+	DUID []byte `json:"-"`
+
+	//Cltt is the client's last transaction time
+	Cltt time.Time `json:"cltt"`
+
+	//Addresses holds a Lease for each iaaddr or iaprefix statement, with IP set to the address, or the start of the prefix with the prefix itself in RawAddress
+	Addresses []Lease `json:"addresses"`
+}
+
+/*parse populates l from an ia-na, ia-ta or ia-pd block*/
+func (l *Lease6) parse(s []byte) {
+	log.WithField("lease6Token", s).Trace("Parsing lease6 token")
+	var addr *bytes.Buffer
+	for len(s) > 0 {
+		var line string
+		line, s = nextLine(s)
+		line = strings.TrimLeft(line, " \t")
+		switch {
+		case addr != nil && line == "}":
+			// end of an iaaddr or iaprefix block
+			a := Lease{}
+			a.parse(addr.Bytes(), &ParseOptions{})
+			if a.IP == nil {
+				if ip, _, err := net.ParseCIDR(a.RawAddress); err == nil {
+					a.IP = ip
+				}
+			}
+			l.Addresses = append(l.Addresses, a)
+			addr = nil
+		case addr != nil:
+			addr.WriteString(line)
+			addr.WriteByte('\n')
+		case strings.HasPrefix(line, "iaaddr ") || strings.HasPrefix(line, "iaprefix "):
+			// iaaddr 2001:db8::100 { is decoded as if it were a v4 lease statement
+			addr = &bytes.Buffer{}
+			addr.WriteString("lease " + strings.SplitN(line, " ", 2)[1] + "\n")
+		case strings.HasPrefix(line, "ia-na ") || strings.HasPrefix(line, "ia-ta ") || strings.HasPrefix(line, "ia-pd "):
+			l.Type = line[:5]
+			l.ID = parseQuotedID(line)
+			if id := unescape(l.ID); len(id) >= 4 {
+				l.IAID = id[:4]
+				l.DUID = id[4:]
+			}
+		case strings.HasPrefix(line, "cltt "):
+			l.Cltt = parseTime(line)
+		}
+	}
+}
+
+/*
+parseQuotedID returns the quoted identifier in s.  Identifiers are binary so may contain escaped
+quotes, which don't end the identifier.
+*/
+func parseQuotedID(s string) string {
+	v, _ := quotedValue(s)
+	return v
+}
+
+/*
+ParseMixed reads a stream holding both DHCPv4 lease blocks and DHCPv6 ia-na, ia-ta and ia-pd blocks,
+such as a combined export from a dual-stack server, returning the leases of each kind in the order
+they appear.  Errors are returned as for ParseWithOptions.
+*/
+func ParseMixed(r io.Reader) ([]Lease, []Lease6, error) {
+	return ParseMixedWithOptions(r, ParseOptions{})
+}
+
+/*
+ParseMixedWithOptions reads a stream as ParseMixed does, handling the input and the DHCPv4 leases
+as set out by opts, as ParseWithOptions does
+*/
+func ParseMixedWithOptions(r io.Reader, opts ParseOptions) ([]Lease, []Lease6, error) {
+	var v4 []Lease
+	var v6 []Lease6
+
+	err := parseMixedBlocks(r, newTokenizer(mixedStartKeywords, 0), opts, func(l Lease) {
+		v4 = append(v4, l)
+	}, func(block []byte) {
+		l := Lease6{}
+		l.parse(block)
+		log.WithFields(log.Fields{
+			"lease6": l,
+		}).Trace("Parsed lease6")
+		v6 = append(v6, l)
+	})
+	return v4, v6, err
+}
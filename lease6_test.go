@@ -0,0 +1,102 @@
+package leases
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseMixed(t *testing.T) {
+	leaseData := `
+lease 172.16.0.60 {
+  binding state active;
+}
+ia-na "\001\000\000\000\000\001\000\001\036\215\352\363\000\014)\257\000\001" {
+  cltt 2 2020/01/07 10:00:00;
+  iaaddr 2001:db8::100 {
+    binding state active;
+    preferred-life 375;
+    max-life 600;
+    ends 2 2020/01/07 10:10:00;
+  }
+  iaaddr 2001:db8::101 {
+    binding state expired;
+  }
+}
+lease 172.16.0.61 {
+  binding state free;
+}
+ia-pd "\002\000\000\000\"{}" {
+  iaprefix 2001:db8:1::/64 {
+    binding state active;
+  }
+}
+`
+	v4, v6, err := ParseMixed(bytes.NewBufferString(leaseData))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(v4) != 2 || v4[0].IP.String() != "172.16.0.60" || v4[1].BindingState != "free" {
+		t.Errorf("unexpected v4 leases %v", v4)
+	}
+	if len(v6) != 2 {
+		t.Fatalf("found %d v6 leases, expected 2", len(v6))
+	}
+
+	na := v6[0]
+	if na.Type != "ia-na" || !bytes.Equal(na.IAID, []byte{1, 0, 0, 0}) || len(na.DUID) != 14 {
+		t.Errorf("unexpected identity association %s %x %x", na.Type, na.IAID, na.DUID)
+	}
+	if want := time.Date(2020, 1, 7, 10, 0, 0, 0, time.UTC); !na.Cltt.Equal(want) {
+		t.Errorf("cltt %v, expected %v", na.Cltt, want)
+	}
+	if len(na.Addresses) != 2 {
+		t.Fatalf("found %d addresses, expected 2", len(na.Addresses))
+	}
+	a := na.Addresses[0]
+	if a.IP.String() != "2001:db8::100" || a.BindingState != "active" || a.PreferredLifetime != 375*time.Second || a.MaxLifetime != 600*time.Second {
+		t.Errorf("unexpected address %s %s %v %v", a.IP, a.BindingState, a.PreferredLifetime, a.MaxLifetime)
+	}
+	if want := time.Date(2020, 1, 7, 10, 10, 0, 0, time.UTC); !a.Ends.Equal(want) {
+		t.Errorf("ends %v, expected %v", a.Ends, want)
+	}
+	if na.Addresses[1].BindingState != "expired" {
+		t.Errorf("unexpected binding state %s", na.Addresses[1].BindingState)
+	}
+
+	pd := v6[1]
+	if pd.Type != "ia-pd" || !bytes.Equal(pd.DUID, []byte(`"{}`)) {
+		t.Errorf("unexpected identity association %s %q", pd.Type, pd.DUID)
+	}
+	if len(pd.Addresses) != 1 || pd.Addresses[0].RawAddress != "2001:db8:1::/64" || pd.Addresses[0].IP.String() != "2001:db8:1::" {
+		t.Errorf("unexpected prefixes %v", pd.Addresses)
+	}
+}
+
+func TestParseMixedWithOptions(t *testing.T) {
+	leaseData := "# export\nlease 172.16.0.60 {\n  hardware ethernet 00:DB:70:C3:11:D7;\n  hardware ethernet;\n}\n" +
+		"ia-na \"\\001\\000\\000\\000\\000\\001\" {\n  set long = \"" + strings.Repeat("x", 70000) + "\";\n" +
+		"  iaaddr 2001:db8::100 {\n    binding state active;\n  }\n}\n"
+
+	v4, v6, err := ParseMixedWithOptions(bytes.NewBufferString(leaseData), ParseOptions{
+		MaxBlockSize:  1 << 20,
+		NormalizeCase: true,
+		Offsets:       true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(v4) != 1 || v4[0].Hardware.MAC != "00:db:70:c3:11:d7" || v4[0].Length == 0 {
+		t.Errorf("expected the options applied to the v4 lease, got %v", v4)
+	}
+	var m *MalformedLeaseError
+	if len(v4) == 1 && (len(v4[0].Errors) != 1 || !errors.As(v4[0].Errors[0], &m) || m.Offset != 9 || v4[0].Offset != 9) {
+		t.Errorf("expected an error at the lease's offset, got %v", v4[0].Errors)
+	}
+	// statements after a line longer than bufio.Scanner's buffer are still read
+	if len(v6) != 1 || len(v6[0].Addresses) != 1 || v6[0].Addresses[0].BindingState != "active" {
+		t.Errorf("unexpected v6 leases %v", v6)
+	}
+}
@@ -0,0 +1,203 @@
+package leases
+
+import (
+	"bytes"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"net"
+	"strings"
+	"unsafe"
+)
+
+var (
+	fullStartKeywords = [][]byte{
+		[]byte("\nlease "),
+		[]byte("\nia-na "),
+		[]byte("\nia-ta "),
+		[]byte("\nia-pd "),
+		[]byte("\nfailover peer "),
+		[]byte("\nauthoring-byte-order "),
+		[]byte("\nserver-duid "),
+		[]byte("\nhost "),
+	}
+)
+
+/*
+File is everything ParseFull understands in a dhcpd.leases file: the leases of both address
+families, the host declarations made through OMAPI, the failover peer states and the statements
+describing the file itself
+*/
+type File struct {
+	//ByteOrder is the authoring-byte-order statement, "little-endian" or "big-endian", or empty if the file has none
+	ByteOrder string `json:"authoring-byte-order,omitempty"`
+
+	//ServerDUID is the quoted DHCPv6 server DUID as written, with its escapes
+	ServerDUID string `json:"server-duid,omitempty"`
+
+	//Globals holds the other top-level statements, such as db-time-format, keyed by name with the rest of the statement as the value
+	Globals map[string]string `json:"globals,omitempty"`
+
+	//Hosts holds the host declarations dhcpd records for hosts added or removed through OMAPI, in the order written
+	Hosts []Host `json:"hosts,omitempty"`
+
+	Leases   []Lease         `json:"leases"`
+	Leases6  []Lease6        `json:"leases6,omitempty"`
+	Failover []FailoverState `json:"failover,omitempty"`
+}
+
+/*
+Host is a host declaration in a dhcpd.leases file, which dhcpd writes when a host is added or
+removed through OMAPI.  The reservation it makes is read as ParseConfig reads host declarations,
+with Host set to the declaration's name.  A later declaration with the same name replaces an
+earlier one.
+*/
+type Host struct {
+	Lease
+
+	//Dynamic is set for a host added through OMAPI rather than declared in dhcpd.conf
+	Dynamic bool `json:"dynamic,omitempty"`
+
+	//Deleted is set for a host removed through OMAPI
+	Deleted bool `json:"deleted,omitempty"`
+}
+
+/*
+ParseFull reads everything it understands from a dhcpd.leases file in a single pass.  A warning is
+logged if the file was written with a different byte order than the host's, see ByteOrderMismatch.
+Errors are returned as for ParseWithOptions, along with what was parsed up to that point.
+*/
+func ParseFull(r io.Reader) (File, error) {
+	var f File
+
+	opts := ParseOptions{}
+	t := newTokenizer(fullStartKeywords, 0)
+	t.gap = &bytes.Buffer{}
+	err := scanBlocks(r, t, func(block []byte) {
+		switch {
+		case bytes.HasPrefix(block, []byte("lease ")):
+			f.Leases = append(f.Leases, parseLeaseBlock(block, t.tokenOffset, false, &opts))
+		case bytes.HasPrefix(block, []byte("failover peer ")):
+			fs := FailoverState{}
+			fs.parse(block)
+			f.Failover = append(f.Failover, fs)
+		case bytes.HasPrefix(block, []byte("authoring-byte-order ")):
+			f.ByteOrder = parseKeyword(string(block), 1)
+		case bytes.HasPrefix(block, []byte("server-duid ")):
+			f.ServerDUID = parseQuotedID(string(block))
+		case bytes.HasPrefix(block, []byte("host ")):
+			if brace := bytes.IndexByte(block, '{'); brace != -1 && block[len(block)-1] == '}' {
+				f.Hosts = append(f.Hosts, parseFileHost(block, brace))
+			}
+		default:
+			l := Lease6{}
+			l.parse(block)
+			f.Leases6 = append(f.Leases6, l)
+		}
+	})
+	f.Globals = parseGlobals(t.gap.Bytes())
+
+	if f.ByteOrderMismatch() {
+		log.WithFields(log.Fields{
+			"fileByteOrder": f.ByteOrder,
+			"hostByteOrder": hostByteOrder(),
+		}).Warn("Leases file was written with a different byte order, binary identifiers may be misread")
+	}
+	return f, err
+}
+
+/*ParseFileStruct reads a dhcpd.leases file as ParseFull does, returning the File by reference*/
+func ParseFileStruct(r io.Reader) (*File, error) {
+	f, err := ParseFull(r)
+	return &f, err
+}
+
+/*parseFileHost returns the host declared by block, whose body starts after the brace at index brace*/
+func parseFileHost(block []byte, brace int) Host {
+	h := Host{Lease: parseHost(strings.Fields(string(block[:brace])), block[brace+1:len(block)-1], nil)}
+	_, h.Dynamic = h.Unknown["dynamic"]
+	_, h.Deleted = h.Unknown["deleted"]
+	delete(h.Unknown, "dynamic")
+	delete(h.Unknown, "deleted")
+	if len(h.Unknown) == 0 {
+		h.Unknown = nil
+	}
+	return h
+}
+
+/*
+parseGlobals returns the statements in d, the text between the blocks of a leases file, keyed by
+name.  Comments and anything other than a statement ending in ';', such as an unknown block, are
+skipped.  It returns nil if there are none.
+*/
+func parseGlobals(d []byte) map[string]string {
+	var globals map[string]string
+	for {
+		d = skipConfigSpace(d)
+		end, found := blockEnd(d)
+		if !found {
+			return globals
+		}
+		stmt := string(d[:end])
+		d = d[end:]
+		if !strings.HasSuffix(stmt, ";") || strings.Contains(stmt, "{") {
+			continue
+		}
+		name, value, _ := strings.Cut(strings.TrimSuffix(stmt, ";"), " ")
+		if globals == nil {
+			globals = map[string]string{}
+		}
+		globals[name] = strings.TrimSpace(value)
+	}
+}
+
+/*
+ByteOrderMismatch returns true if the file declares an authoring-byte-order different from the
+host's.  Multi-byte integers dhcpd copies into binary identifiers, such as the IAID at the start of
+a Lease6 ID, are then in the opposite order to the host's.  Files without the statement never
+mismatch.
+*/
+func (f File) ByteOrderMismatch() bool {
+	return f.ByteOrder != "" && f.ByteOrder != hostByteOrder()
+}
+
+/*
+ActiveLeases returns the current lease for each IP whose binding state is active, sorted by IP.
+Earlier records for an IP are ignored, as for CurrentByIP.
+*/
+func (f File) ActiveLeases() []Lease {
+	var rtn []Lease
+	for _, l := range f.CurrentByIP() {
+		if l.BindingState == "active" {
+			rtn = append(rtn, l)
+		}
+	}
+	sortByIP(rtn)
+	return rtn
+}
+
+/*CurrentByIP returns the current DHCPv4 lease for each IP in the file, see CurrentByIP*/
+func (f File) CurrentByIP() map[string]Lease {
+	return CurrentByIP(f.Leases)
+}
+
+/*
+FindMAC returns the latest lease in the file for the client with the given MAC address, whatever
+its state.  mac may be in any form net.ParseMAC accepts, in either case.
+*/
+func (f File) FindMAC(mac string) (Lease, bool) {
+	m, err := net.ParseMAC(mac)
+	if err != nil {
+		return Lease{}, false
+	}
+	l, ok := LatestByMAC(f.Leases)[m.String()]
+	return l, ok
+}
+
+/*hostByteOrder returns the byte order of the host as dhcpd writes it*/
+func hostByteOrder() string {
+	x := uint16(1)
+	if *(*byte)(unsafe.Pointer(&x)) == 1 {
+		return "little-endian"
+	}
+	return "big-endian"
+}
@@ -0,0 +1,135 @@
+package leases
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestParseFull(t *testing.T) {
+	leaseData := `# This lease file was written by isc-dhcp-4.3.6-P1
+
+# authoring-byte-order entry is generated, DO NOT DELETE
+authoring-byte-order little-endian;
+db-time-format local;
+lease-id-format octal; # written by newer versions
+
+lease 172.24.43.3 {
+  client-hostname "gertrude";
+  binding state active;
+}
+lease 172.24.43.4 {
+  binding state free;
+}
+host printer {
+  dynamic;
+  hardware ethernet 00:db:70:c3:11:d7;
+  fixed-address 172.24.43.10;
+}
+host scanner {
+  dynamic;
+  deleted;
+}
+server-duid "\000\001\000\001\036\215\352\363\000\014)\257\000\001";
+
+failover peer "dhcp-failover" state {
+  my state normal at 4 2019/04/25 12:00:00;
+  partner state normal at 4 2019/04/25 11:59:00;
+}
+ia-na "\001\000\000\000\000\001\000\001\036\215\352\363\000\014)\257\000\001" {
+  iaaddr 2001:db8::100 {
+    binding state active;
+  }
+}
+`
+	f, err := ParseFull(bytes.NewBufferString(leaseData))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if f.ByteOrder != "little-endian" {
+		t.Errorf("byte order is %q, expected little-endian", f.ByteOrder)
+	}
+	if f.ServerDUID != `\000\001\000\001\036\215\352\363\000\014)\257\000\001` {
+		t.Errorf("unexpected server duid %q", f.ServerDUID)
+	}
+	if want := map[string]string{"db-time-format": "local", "lease-id-format": "octal"}; !reflect.DeepEqual(f.Globals, want) {
+		t.Errorf("globals are %v, expected %v", f.Globals, want)
+	}
+	if len(f.Hosts) != 2 {
+		t.Fatalf("found %d hosts, expected 2: %v", len(f.Hosts), f.Hosts)
+	}
+	if h := f.Hosts[0]; h.Host != "printer" || h.IP.String() != "172.24.43.10" || !h.Reserved || !h.Dynamic || h.Deleted || h.Unknown != nil {
+		t.Errorf("unexpected host %v", h)
+	}
+	if h := f.Hosts[1]; h.Host != "scanner" || !h.Dynamic || !h.Deleted {
+		t.Errorf("unexpected deleted host %v", h)
+	}
+	if len(f.Leases) != 2 || f.Leases[0].ClientHostname != "gertrude" {
+		t.Errorf("unexpected leases %v", f.Leases)
+	}
+	if len(f.Failover) != 1 || f.Failover[0].Peer != "dhcp-failover" {
+		t.Errorf("unexpected failover states %v", f.Failover)
+	}
+	if len(f.Leases6) != 1 || len(f.Leases6[0].Addresses) != 1 {
+		t.Errorf("unexpected v6 leases %v", f.Leases6)
+	}
+}
+
+func TestFileAccessors(t *testing.T) {
+	leaseData := `
+lease 172.24.43.4 {
+  binding state active;
+  hardware ethernet 00:db:70:c3:11:d7;
+}
+lease 172.24.43.3 {
+  binding state active;
+  hardware ethernet 00:db:70:c3:11:d8;
+}
+lease 172.24.43.5 {
+  binding state free;
+}
+lease 172.24.43.4 {
+  binding state free;
+  hardware ethernet 00:DB:70:C3:11:D7;
+}
+`
+	f, err := ParseFileStruct(bytes.NewBufferString(leaseData))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if current := f.CurrentByIP(); len(current) != 3 || current["172.24.43.4"].BindingState != "free" {
+		t.Errorf("unexpected current leases %v", current)
+	}
+	if active := f.ActiveLeases(); len(active) != 1 || active[0].IP.String() != "172.24.43.3" {
+		t.Errorf("unexpected active leases %v", active)
+	}
+	if l, ok := f.FindMAC("00-DB-70-C3-11-D7"); !ok || l.BindingState != "free" {
+		t.Errorf("expected the latest lease for the MAC, got %v, %v", l, ok)
+	}
+	if _, ok := f.FindMAC("00:db:70:c3:11:d9"); ok {
+		t.Error("expected no lease for an unknown MAC")
+	}
+	if _, ok := f.FindMAC("not a mac"); ok {
+		t.Error("expected no lease for an invalid MAC")
+	}
+}
+
+func TestByteOrderMismatch(t *testing.T) {
+	other := "big-endian"
+	if hostByteOrder() == other {
+		other = "little-endian"
+	}
+
+	for _, tc := range []struct {
+		order string
+		want  bool
+	}{
+		{hostByteOrder(), false},
+		{other, true},
+		{"", false},
+	} {
+		if got := (File{ByteOrder: tc.order}).ByteOrderMismatch(); got != tc.want {
+			t.Errorf("mismatch for %q is %v, expected %v", tc.order, got, tc.want)
+		}
+	}
+}
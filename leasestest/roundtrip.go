@@ -0,0 +1,46 @@
+/*
+Package leasestest provides helpers for testing code built on package leases, such as custom
+decoders.
+*/
+package leasestest
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	leases "github.com/nijave/go-dhcpd-leases"
+)
+
+/*
+AssertRoundTrip parses raw, marshals the leases back into the dhcpd.leases format and parses them
+again, failing t if any lease differs between the two parses.  Offset, Length, Source and Errors
+describe where and how a lease was parsed rather than the lease itself, so are not compared.
+*/
+func AssertRoundTrip(t testing.TB, raw []byte) {
+	t.Helper()
+
+	first := leases.Parse(bytes.NewReader(raw))
+	marshaled := leases.Marshal(first)
+	second := leases.Parse(bytes.NewReader(marshaled))
+
+	if len(first) != len(second) {
+		t.Errorf("parsed %d leases but %d after marshaling them:\n%s", len(first), len(second), marshaled)
+		return
+	}
+	for i := range first {
+		a, b := withoutParseDetails(first[i]), withoutParseDetails(second[i])
+		if !reflect.DeepEqual(a, b) {
+			t.Errorf("lease %d changed marshaling it:\n%+v\n%+v", i, a, b)
+		}
+	}
+}
+
+/*withoutParseDetails clears the fields describing how l was parsed*/
+func withoutParseDetails(l leases.Lease) leases.Lease {
+	l.Offset = 0
+	l.Length = 0
+	l.Source = ""
+	l.Errors = nil
+	return l
+}
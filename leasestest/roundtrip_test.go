@@ -0,0 +1,62 @@
+package leasestest
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestAssertRoundTrip(t *testing.T) {
+	AssertRoundTrip(t, []byte(`
+lease 172.16.0.60 {
+  starts 4 2022/03/31 15:52:00;
+  ends 4 2022/03/31 19:52:00;
+  cltt 4 2022/03/31 15:52:00;
+  binding state active;
+  next binding state free;
+  rewind binding state free;
+  hardware ethernet 00:00:00:00:00:01;
+  uid "\001\000\356\275\264\276j";
+  option agent.circuit-id "eth 1/0/24";
+  client-hostname "m8";
+}
+lease 172.16.0.67 {
+  starts 4 2022/03/31 16:27:59;
+  ends never;
+  binding state active;
+  reserved;
+  uid "\377v_}\212\000\002\000\000\253\021A\015\020,J\275b\\";
+}
+lease 172.16.0.68 {
+  starts 4 2022/03/31 16:27:59;
+  binding state active;
+  hardware ethernet 00:ee:bd:b4:be:6a;
+  uid 01:00:ee:bd:b4:be:6a;
+}
+`))
+}
+
+// recorder captures failures instead of failing the test using it
+type recorder struct {
+	testing.TB
+	failures []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.failures = append(r.failures, fmt.Sprintf(format, args...))
+}
+
+func TestAssertRoundTripDetectsLoss(t *testing.T) {
+	r := &recorder{TB: t}
+
+	// the invalid hex uid is kept as text and written back quoted, so decodes differently
+	AssertRoundTrip(r, []byte(`
+lease 172.16.0.60 {
+  uid 01:zz;
+}
+`))
+	if len(r.failures) != 1 {
+		t.Errorf("expected one failure, got %q", r.failures)
+	}
+}
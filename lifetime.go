@@ -0,0 +1,174 @@
+package leases
+
+import (
+	"sort"
+	"time"
+)
+
+/*
+Duration returns the length of the lease from Starts to Ends.  Zero is returned if either time is
+missing or the lease never ends.
+*/
+func (l Lease) Duration() time.Duration {
+	if l.Starts.IsZero() || l.Ends.IsZero() || l.Ends.Equal(Never) || l.Starts.Equal(Never) {
+		return 0
+	}
+	return l.Ends.Sub(l.Starts)
+}
+
+/*
+EstimatedRenewalTime returns when the client should have renewed the lease, assuming it renews at
+the usual T1 of half the lease time.  A lease still active well after this suggests the client has
+gone away.  The zero time is returned if the lease has no duration.
+*/
+func (l Lease) EstimatedRenewalTime() time.Time {
+	d := l.Duration()
+	if d <= 0 {
+		return time.Time{}
+	}
+	return l.Starts.Add(d / 2)
+}
+
+/*
+IsActive returns true if the lease's binding state is active and it hasn't ended by now.  Leases
+without an end time, such as abandoned leases that only record their binding state, aren't
+active.
+*/
+func (l Lease) IsActive(now time.Time) bool {
+	return l.BindingState == "active" && l.Ends.After(now)
+}
+
+/*
+HasBindingState returns true if the lease recorded a binding state.  Lease blocks from very old
+versions of dhcpd, or partial blocks, may not, in which case IsActive is always false unless the
+lease was parsed with ParseOptions.AssumeActive.
+*/
+func (l Lease) HasBindingState() bool {
+	return l.BindingState != "" && !l.assumedState
+}
+
+/*
+StateSummary describes the lease's binding state and the state it moves to when it ends, such as
+"active→free (expires 2019-04-27T03:34:45Z)".  The transition is left out if there is no next
+binding state, and the expiry if the lease has no end time.  Leases that never end are described
+as "active (never expires)".
+*/
+func (l Lease) StateSummary() string {
+	state := l.BindingState
+	if state == "" {
+		state = "unknown"
+	}
+
+	switch {
+	case l.Ends.Equal(Never):
+		return state + " (never expires)"
+	case l.NextBindingState != "" && !l.Ends.IsZero():
+		return state + "→" + l.NextBindingState + " (expires " + l.Ends.UTC().Format(time.RFC3339) + ")"
+	case l.NextBindingState != "":
+		return state + "→" + l.NextBindingState
+	case !l.Ends.IsZero():
+		return state + " (expires " + l.Ends.UTC().Format(time.RFC3339) + ")"
+	}
+	return state
+}
+
+/*
+WeekdayMismatches returns the names of the timestamps whose recorded weekday doesn't match their
+date, such as "starts" for "starts 5 2019/04/27 03:24:45;" when the 27th was a Saturday.  It needs
+the raw timestamps kept by ParseOptions.KeepRawTimes, and skips timestamps without a weekday.
+*/
+func (l Lease) WeekdayMismatches() []string {
+	var rtn []string
+	for _, ts := range []struct {
+		name string
+		raw  string
+		t    time.Time
+	}{
+		{"starts", l.StartsRaw, l.Starts},
+		{"ends", l.EndsRaw, l.Ends},
+		{"tstp", l.TstpRaw, l.Tstp},
+		{"tsfp", l.TsfpRaw, l.Tsfp},
+		{"atsfp", l.AtsfpRaw, l.Atsfp},
+		{"cltt", l.ClttRaw, l.Cltt},
+	} {
+		if len(ts.raw) < 2 || ts.raw[1] != ' ' || ts.raw[0] < '0' || ts.raw[0] > '6' || ts.t.IsZero() || ts.t.Equal(Never) {
+			continue
+		}
+		if time.Weekday(ts.raw[0]-'0') != ts.t.Weekday() {
+			rtn = append(rtn, ts.name)
+		}
+	}
+	return rtn
+}
+
+/*
+StartsTime returns when the lease starts, with false if the lease didn't record a start time, or
+recorded one that couldn't be parsed, rather than the zero time
+*/
+func (l Lease) StartsTime() (time.Time, bool) { return l.Starts, !l.Starts.IsZero() }
+
+/*EndsTime is like StartsTime for Ends.  Leases that never end return Never with true*/
+func (l Lease) EndsTime() (time.Time, bool) { return l.Ends, !l.Ends.IsZero() }
+
+/*ClttTime is like StartsTime for Cltt*/
+func (l Lease) ClttTime() (time.Time, bool) { return l.Cltt, !l.Cltt.IsZero() }
+
+/*TstpTime is like StartsTime for Tstp*/
+func (l Lease) TstpTime() (time.Time, bool) { return l.Tstp, !l.Tstp.IsZero() }
+
+/*TsfpTime is like StartsTime for Tsfp*/
+func (l Lease) TsfpTime() (time.Time, bool) { return l.Tsfp, !l.Tsfp.IsZero() }
+
+/*AtsfpTime is like StartsTime for Atsfp*/
+func (l Lease) AtsfpTime() (time.Time, bool) { return l.Atsfp, !l.Atsfp.IsZero() }
+
+/*
+ClientSessionLength estimates how long a client has been on the network without a break, from the
+records of its leases in history, such as those from ParseAll.  Only the records with the same
+ClientKey as the last record with one are used, so history can hold other clients' records too.
+The session runs from the start of the earliest of a chain of the client's leases to the client's
+last transaction time, or the start of its latest lease if that isn't recorded.  Leases form a
+chain while each starts before the one before it ends: a gap between one lease ending and the next
+starting is taken as the client having left, ending the earlier session.  Zero is returned if the
+client has no records with a start time.
+*/
+func ClientSessionLength(history []Lease) time.Duration {
+	key := ""
+	for i := len(history) - 1; i >= 0 && key == ""; i-- {
+		key = history[i].ClientKey()
+	}
+	if key == "" {
+		return 0
+	}
+
+	var records []Lease
+	for _, l := range history {
+		if l.ClientKey() == key && !l.Starts.IsZero() && !l.Starts.Equal(Never) {
+			records = append(records, l)
+		}
+	}
+	if len(records) == 0 {
+		return 0
+	}
+	sort.SliceStable(records, func(i, j int) bool { return records[i].Starts.Before(records[j].Starts) })
+
+	latest := records[len(records)-1]
+	start, end := latest.Starts, latest.Cltt
+	for i := len(records) - 2; i >= 0; i-- {
+		l := records[i]
+		if !l.Ends.IsZero() && !l.Ends.Equal(Never) && l.Ends.Before(start) {
+			break
+		}
+		start = l.Starts
+		if l.Cltt.After(end) {
+			end = l.Cltt
+		}
+	}
+	if end.IsZero() || end.Equal(Never) {
+		end = latest.Starts
+	}
+	if end.Before(start) {
+		return 0
+	}
+	return end.Sub(start)
+}
@@ -0,0 +1,209 @@
+package leases
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEstimatedRenewalTime(t *testing.T) {
+	starts := time.Date(2019, 4, 27, 3, 24, 45, 0, time.UTC)
+
+	l := Lease{Starts: starts, Ends: starts.Add(10 * time.Minute)}
+	if d := l.Duration(); d != 10*time.Minute {
+		t.Errorf("duration %v, expected 10m", d)
+	}
+	if want := starts.Add(5 * time.Minute); !l.EstimatedRenewalTime().Equal(want) {
+		t.Errorf("renewal time %v, expected %v", l.EstimatedRenewalTime(), want)
+	}
+
+	for _, l := range []Lease{
+		{Starts: starts, Ends: Never},
+		{Starts: starts},
+		{Ends: starts},
+		{},
+	} {
+		if l.Duration() != 0 {
+			t.Errorf("%v shouldn't have a duration", l)
+		}
+		if !l.EstimatedRenewalTime().IsZero() {
+			t.Errorf("%v shouldn't have a renewal time", l)
+		}
+	}
+}
+
+func TestStateSummary(t *testing.T) {
+	ends := time.Date(2019, 4, 27, 3, 34, 45, 0, time.UTC)
+
+	for want, l := range map[string]Lease{
+		"active→free (expires 2019-04-27T03:34:45Z)": {BindingState: "active", NextBindingState: "free", Ends: ends},
+		"active (expires 2019-04-27T03:34:45Z)":      {BindingState: "active", Ends: ends},
+		"active→free":                                {BindingState: "active", NextBindingState: "free"},
+		"active (never expires)":                     {BindingState: "active", NextBindingState: "free", Ends: Never},
+		"free":                                       {BindingState: "free"},
+		"unknown":                                    {},
+	} {
+		if got := l.StateSummary(); got != want {
+			t.Errorf("summary %q, expected %q", got, want)
+		}
+	}
+}
+
+func TestStatelessLease(t *testing.T) {
+	leaseData := `
+lease 172.16.0.60 {
+  starts 6 2019/04/27 03:24:45;
+  ends 6 2119/04/27 03:34:45;
+  hardware ethernet 00:db:70:c3:11:d7;
+}
+lease 172.16.0.61 {
+  starts 6 2019/04/27 03:24:45;
+  ends 6 2019/04/27 03:34:45;
+}
+lease 172.16.0.62 {
+  ends 6 2119/04/27 03:34:45;
+  binding state free;
+}
+`
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	strict, _ := ParseWithOptions(bytes.NewBufferString(leaseData), ParseOptions{})
+	legacy, _ := ParseWithOptions(bytes.NewBufferString(leaseData), ParseOptions{AssumeActive: true})
+	if len(strict) != 3 || len(legacy) != 3 {
+		t.Fatalf("found %d and %d leases, expected 3", len(strict), len(legacy))
+	}
+
+	for i, want := range []struct {
+		hasState     bool
+		active       bool
+		legacyActive bool
+	}{
+		{false, false, true},
+		{false, false, false},
+		{true, false, false},
+	} {
+		if strict[i].HasBindingState() != want.hasState || legacy[i].HasBindingState() != want.hasState {
+			t.Errorf("lease %d has binding state %v, %v, expected %v", i, strict[i].HasBindingState(), legacy[i].HasBindingState(), want.hasState)
+		}
+		if strict[i].IsActive(now) != want.active {
+			t.Errorf("lease %d active is %v, expected %v", i, strict[i].IsActive(now), want.active)
+		}
+		if legacy[i].IsActive(now) != want.legacyActive {
+			t.Errorf("lease %d assumed active is %v, expected %v", i, legacy[i].IsActive(now), want.legacyActive)
+		}
+	}
+}
+
+func TestWeekdayMismatches(t *testing.T) {
+	leaseData := `
+lease 172.16.0.60 {
+  starts 5 2019/04/27 03:24:45;
+  ends 6 2019/04/27 03:34:45;
+  tstp 2019/04/27 03:34:45;
+  tsfp never;
+  cltt 0 2019/04/27 03:24:45;
+}
+`
+	plain := Parse(bytes.NewBufferString(leaseData))
+	if len(plain) != 1 || plain[0].StartsRaw != "" {
+		t.Fatalf("raw times shouldn't be kept by default, got %v", plain)
+	}
+
+	leases, _ := ParseWithOptions(bytes.NewBufferString(leaseData), ParseOptions{KeepRawTimes: true})
+	if len(leases) != 1 {
+		t.Fatalf("found %d leases, expected 1", len(leases))
+	}
+	l := leases[0]
+	if l.StartsRaw != "5 2019/04/27 03:24:45" || l.TsfpRaw != "never" || l.AtsfpRaw != "" {
+		t.Errorf("unexpected raw times %q, %q, %q", l.StartsRaw, l.TsfpRaw, l.AtsfpRaw)
+	}
+	if got := l.WeekdayMismatches(); !reflect.DeepEqual(got, []string{"starts", "cltt"}) {
+		t.Errorf("mismatched weekdays %q, expected starts and cltt", got)
+	}
+}
+
+func TestOptionalTimes(t *testing.T) {
+	leaseData := `
+lease 172.16.0.60 {
+  starts 6 2019/04/27 03:24:45;
+  ends never;
+  cltt 6 2019/04/27 03:24:45;
+  tstp 6 not a date;
+}
+`
+	leases := Parse(bytes.NewBufferString(leaseData))
+	if len(leases) != 1 {
+		t.Fatalf("found %d leases, expected 1", len(leases))
+	}
+	l := leases[0]
+
+	starts := time.Date(2019, 4, 27, 3, 24, 45, 0, time.UTC)
+	for name, get := range map[string]func() (time.Time, bool){
+		"starts": l.StartsTime,
+		"cltt":   l.ClttTime,
+	} {
+		if ts, ok := get(); !ok || !ts.Equal(starts) {
+			t.Errorf("%s is %v, %v, expected %v", name, ts, ok, starts)
+		}
+	}
+	if ts, ok := l.EndsTime(); !ok || !ts.Equal(Never) {
+		t.Errorf("ends is %v, %v, expected Never", ts, ok)
+	}
+	for name, get := range map[string]func() (time.Time, bool){
+		"tstp":  l.TstpTime,
+		"tsfp":  l.TsfpTime,
+		"atsfp": l.AtsfpTime,
+	} {
+		if ts, ok := get(); ok {
+			t.Errorf("%s should be unset, got %v", name, ts)
+		}
+	}
+}
+
+func TestClientSessionLength(t *testing.T) {
+	history := Parse(bytes.NewBufferString(`
+lease 10.0.0.5 {
+  starts 1 2020/01/06 08:00:00;
+  ends 1 2020/01/06 09:00:00;
+  cltt 1 2020/01/06 08:00:00;
+  hardware ethernet 00:db:70:c3:11:d7;
+}
+lease 10.0.0.5 {
+  starts 2 2020/01/07 08:00:00;
+  ends 2 2020/01/07 09:00:00;
+  cltt 2 2020/01/07 08:00:00;
+  hardware ethernet 00:db:70:c3:11:d7;
+}
+lease 10.0.0.6 {
+  starts 2 2020/01/07 07:00:00;
+  ends 2 2020/01/07 12:00:00;
+  cltt 2 2020/01/07 11:00:00;
+  hardware ethernet 00:db:70:c3:11:d8;
+}
+lease 10.0.0.5 {
+  starts 2 2020/01/07 08:30:00;
+  ends 2 2020/01/07 09:30:00;
+  cltt 2 2020/01/07 08:30:00;
+  hardware ethernet 00:db:70:c3:11:d7;
+}
+lease 10.0.0.7 {
+  starts 2 2020/01/07 09:00:00;
+  ends 2 2020/01/07 10:00:00;
+  cltt 2 2020/01/07 09:15:00;
+  uid "\001\000\333p\303\021\327";
+}
+`))
+
+	// the previous day's lease is separated by a gap, the last three records chain together,
+	// matching the uid to the MAC, and the other client's record is ignored
+	if got, want := ClientSessionLength(history), 75*time.Minute; got != want {
+		t.Errorf("session length is %v, expected %v", got, want)
+	}
+	if got, want := ClientSessionLength(history[:2]), time.Duration(0); got != want {
+		t.Errorf("session of a single lease with no later transaction is %v, expected %v", got, want)
+	}
+	if got := ClientSessionLength(nil); got != 0 {
+		t.Errorf("expected no session without history, got %v", got)
+	}
+}
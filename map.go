@@ -0,0 +1,93 @@
+package leases
+
+import (
+	"encoding/hex"
+	"time"
+)
+
+/*
+ToMap returns the lease's fields as a flat map for use in text/template and similar, so templates
+can use {{ .ip }}, {{ .mac }} or {{ .ends }} without knowing the Lease struct.  Keys use
+underscores rather than dashes so templates can refer to them directly.  Times are formatted as
+RFC 3339 in UTC, "never" for leases that never end and "" when missing.  mac is the normalised, lower
+case MAC and uid_hex the decoded client identifier in hexadecimal.
+*/
+func (l Lease) ToMap() map[string]any {
+	ip := l.RawAddress
+	if l.IP != nil {
+		ip = l.IP.String()
+	}
+	mac := ""
+	if l.Hardware.MACAddr != nil {
+		mac = l.Hardware.MACAddr.String()
+	}
+	subnet := ""
+	if l.Subnet != nil {
+		subnet = l.Subnet.String()
+	}
+	options := make(map[string]string, len(l.Options))
+	for k, v := range l.Options {
+		options[k] = v
+	}
+
+	assignments := make(map[string]string, len(l.Assignments))
+	for k, v := range l.Assignments {
+		assignments[k] = v
+	}
+
+	unknown := make(map[string]string, len(l.Unknown))
+	for k, v := range l.Unknown {
+		unknown[k] = v
+	}
+
+	return map[string]any{
+		"ip":                   ip,
+		"starts":               formatMapTime(l.Starts),
+		"ends":                 formatMapTime(l.Ends),
+		"tstp":                 formatMapTime(l.Tstp),
+		"tsfp":                 formatMapTime(l.Tsfp),
+		"atsfp":                formatMapTime(l.Atsfp),
+		"cltt":                 formatMapTime(l.Cltt),
+		"binding_state":        l.BindingState,
+		"next_binding_state":   l.NextBindingState,
+		"rewind_binding_state": l.RewindBindingState,
+		"hardware":             l.Hardware.Hardware,
+		"mac":                  mac,
+		"uid":                  l.UID,
+		"uid_hex":              hex.EncodeToString(l.UIDBytes),
+		"client_hostname":      l.ClientHostname,
+		"option_host_name":     l.OptionHostName,
+		"reserved":             l.Reserved,
+		"bootp":                l.Bootp,
+		"preferred_life":       l.PreferredLifetime.String(),
+		"max_life":             l.MaxLifetime.String(),
+		"options":              options,
+		"assignments":          assignments,
+		"unknown":              unknown,
+		"comments":             append([]string{}, l.Comments...),
+		"source":               l.Source,
+		"subnet":               subnet,
+		"host":                 l.Host,
+		"partial":              l.Partial,
+	}
+}
+
+/*ToMaps returns each of leases as a map, as ToMap does, for ranging over in a template*/
+func ToMaps(leases []Lease) []map[string]any {
+	rtn := make([]map[string]any, len(leases))
+	for i, l := range leases {
+		rtn[i] = l.ToMap()
+	}
+	return rtn
+}
+
+/*formatMapTime formats t for ToMap*/
+func formatMapTime(t time.Time) string {
+	switch {
+	case t.IsZero():
+		return ""
+	case t.Equal(Never):
+		return "never"
+	}
+	return t.UTC().Format(time.RFC3339)
+}
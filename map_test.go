@@ -0,0 +1,56 @@
+package leases
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+func TestToMap(t *testing.T) {
+	leaseData := `
+lease 172.16.0.60 {
+  starts 6 2019/04/27 03:24:45;
+  ends never;
+  binding state active;
+  hardware ethernet 00:DB:70:C3:11:D7;
+  uid "\001\000\333p\303\021\327";
+  option routers 10.0.0.1;
+  client-hostname "m8";
+}
+`
+	leases := Parse(bytes.NewBufferString(leaseData))
+	if len(leases) != 1 {
+		t.Fatalf("found %d leases, expected 1", len(leases))
+	}
+	maps := ToMaps(leases)
+	if len(maps) != 1 {
+		t.Fatalf("found %d maps, expected 1", len(maps))
+	}
+	m := maps[0]
+
+	for key, want := range map[string]any{
+		"ip":              "172.16.0.60",
+		"starts":          "2019-04-27T03:24:45Z",
+		"ends":            "never",
+		"tstp":            "",
+		"binding_state":   "active",
+		"mac":             "00:db:70:c3:11:d7",
+		"uid_hex":         "0100db70c311d7",
+		"client_hostname": "m8",
+		"reserved":        false,
+	} {
+		if m[key] != want {
+			t.Errorf("%s is %v, expected %v", key, m[key], want)
+		}
+	}
+
+	tmpl := template.Must(template.New("lease").Parse(`{{ .ip }} {{ .mac }} {{ .ends }} {{ index .options "routers" }}`))
+	var out strings.Builder
+	if err := tmpl.Execute(&out, m); err != nil {
+		t.Fatal(err)
+	}
+	if want := "172.16.0.60 00:db:70:c3:11:d7 never 10.0.0.1"; out.String() != want {
+		t.Errorf("template gave %q, expected %q", out.String(), want)
+	}
+}
@@ -0,0 +1,89 @@
+package leases
+
+import (
+	"net"
+	"strings"
+)
+
+/*
+OptionIP returns the named option as an IP address.  The value may be an address literal, quoted
+or not, or a colon separated list of hexadecimal octets as dhcpd writes options it has no format
+for.  Only the first address of a list is returned.  false is returned if the option is missing or
+isn't an address.
+*/
+func (l Lease) OptionIP(name string) (net.IP, bool) {
+	v, ok := l.Options[name]
+	if !ok {
+		return nil, false
+	}
+
+	v = strings.Trim(v, "\"")
+	if i := strings.IndexAny(v, ", "); i != -1 {
+		v = v[:i]
+	}
+	if ip := net.ParseIP(v); ip != nil {
+		return ip, true
+	}
+	if b, err := parseHexList(v); err == nil && (len(b) == net.IPv4len || len(b) == net.IPv6len) {
+		return net.IP(b), true
+	}
+	return nil, false
+}
+
+/*
+RelayAgentInfo holds the relay agent information (option 82) sub-options dhcpd records for leases
+given out through a relay, such as a switch identifying the port a client is on
+*/
+type RelayAgentInfo struct {
+	//CircuitID identifies the circuit, often a switch port, the request came in on
+	CircuitID []byte `json:"circuit-id,omitempty"`
+
+	//RemoteID identifies the relay agent itself
+	RemoteID []byte `json:"remote-id,omitempty"`
+}
+
+/*
+RelayAgent returns the agent.circuit-id and agent.remote-id options recorded with the lease.  false
+is returned if neither is present.
+*/
+func (l Lease) RelayAgent() (RelayAgentInfo, bool) {
+	circuit, cok := l.OptionBytes("agent.circuit-id")
+	remote, rok := l.OptionBytes("agent.remote-id")
+	return RelayAgentInfo{CircuitID: circuit, RemoteID: remote}, cok || rok
+}
+
+/*
+CircuitIDString returns the circuit ID as text, such as "eth 1/0/24", if it is entirely printable
+ASCII.  false is returned otherwise.
+*/
+func (r RelayAgentInfo) CircuitIDString() (string, bool) {
+	if len(r.CircuitID) == 0 {
+		return "", false
+	}
+	for _, c := range r.CircuitID {
+		if c < 0x20 || c > 0x7e {
+			return "", false
+		}
+	}
+	return string(r.CircuitID), true
+}
+
+/*
+OptionBytes returns the raw bytes of the named option.  The value may be a quoted string, with
+octal escapes for non-printable bytes, or a colon separated list of hexadecimal octets.  false is
+returned if the option is missing or is neither.
+*/
+func (l Lease) OptionBytes(name string) ([]byte, bool) {
+	v, ok := l.Options[name]
+	if !ok {
+		return nil, false
+	}
+
+	if len(v) >= 2 && v[0] == '"' && v[len(v)-1] == '"' {
+		return unescape(v[1 : len(v)-1]), true
+	}
+	if b, err := parseHexList(v); err == nil {
+		return b, true
+	}
+	return nil, false
+}
@@ -0,0 +1,104 @@
+package leases
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestLeaseOptions(t *testing.T) {
+	leaseData := `
+lease 172.16.0.60 {
+  binding state active;
+  option domain-name "example.com";
+  option routers 10.0.0.1;
+  option domain-name-servers 10.0.0.2, 10.0.0.3;
+  option dhcp-parameter-request-list 1:3:6;
+  option server.next-server a:0:0:4;
+}
+`
+	leases := Parse(bytes.NewBufferString(leaseData))
+	if len(leases) != 1 {
+		t.Fatalf("found %d leases, expected 1", len(leases))
+	}
+	l := leases[0]
+
+	for name, want := range map[string]string{
+		"domain-name":                 `"example.com"`,
+		"routers":                     "10.0.0.1",
+		"domain-name-servers":         "10.0.0.2, 10.0.0.3",
+		"dhcp-parameter-request-list": "1:3:6",
+	} {
+		if l.Options[name] != want {
+			t.Errorf("option %s is %q, expected %q", name, l.Options[name], want)
+		}
+	}
+
+	for name, want := range map[string]string{
+		"routers":             "10.0.0.1",
+		"domain-name-servers": "10.0.0.2",
+		"server.next-server":  "10.0.0.4",
+	} {
+		if ip, ok := l.OptionIP(name); !ok || ip.String() != want {
+			t.Errorf("option %s as an IP is %v, %v, expected %s", name, ip, ok, want)
+		}
+	}
+
+	for _, name := range []string{"domain-name", "dhcp-parameter-request-list", "missing"} {
+		if ip, ok := l.OptionIP(name); ok {
+			t.Errorf("option %s shouldn't be an IP, got %v", name, ip)
+		}
+	}
+
+	again := Parse(bytes.NewBuffer(Marshal(leases)))
+	if len(again) != 1 || len(again[0].Options) != len(l.Options) {
+		t.Errorf("options should survive marshaling, got %v", again)
+	}
+}
+
+func TestLeaseRelayAgent(t *testing.T) {
+	leaseData := `
+lease 172.16.0.60 {
+  option agent.circuit-id "eth 1/0/24";
+  option agent.remote-id 0:1a:2b:3c:4d:5e;
+}
+lease 172.16.0.61 {
+  option agent.circuit-id "\000\004\000\001\000\030";
+}
+lease 172.16.0.62 {
+  binding state active;
+}
+`
+	leases := Parse(bytes.NewBufferString(leaseData))
+	if len(leases) != 3 {
+		t.Fatalf("found %d leases, expected 3", len(leases))
+	}
+
+	info, ok := leases[0].RelayAgent()
+	if !ok {
+		t.Fatal("expected relay agent information")
+	}
+	if s, ok := info.CircuitIDString(); !ok || s != "eth 1/0/24" {
+		t.Errorf("circuit id is %q, %v, expected eth 1/0/24", s, ok)
+	}
+	if want := []byte{0x00, 0x1a, 0x2b, 0x3c, 0x4d, 0x5e}; !bytes.Equal(info.RemoteID, want) {
+		t.Errorf("remote id is %x, expected %x", info.RemoteID, want)
+	}
+
+	info, ok = leases[1].RelayAgent()
+	if !ok {
+		t.Fatal("expected relay agent information")
+	}
+	if want := []byte{0, 4, 0, 1, 0, 24}; !bytes.Equal(info.CircuitID, want) {
+		t.Errorf("circuit id is %x, expected %x", info.CircuitID, want)
+	}
+	if s, ok := info.CircuitIDString(); ok {
+		t.Errorf("binary circuit id shouldn't be a string, got %q", s)
+	}
+	if info.RemoteID != nil {
+		t.Errorf("remote id should be missing, got %x", info.RemoteID)
+	}
+
+	if info, ok := leases[2].RelayAgent(); ok {
+		t.Errorf("expected no relay agent information, got %v", info)
+	}
+}
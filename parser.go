@@ -3,71 +3,621 @@ package leases
 import (
 	"bufio"
 	"bytes"
+	"errors"
 	"fmt"
 	log "github.com/sirupsen/logrus"
 	"io"
+	"regexp"
 )
 
 var (
-	leaseStartKeyword = []byte("\nlease ")
-	leaseEndKeyword   = []byte{'\n', '}'}
+	//ErrTruncated is returned when the stream ends part way through a block, such as while dhcpd is still writing it, so reading again later may succeed
+	ErrTruncated = errors.New("unterminated block at end of input")
+
+	//ErrTokenTooLarge is returned when a block is larger than ParseOptions.MaxBlockSize. It is bufio.ErrTooLong, so either can be checked for
+	ErrTokenTooLarge = bufio.ErrTooLong
+
+	//ErrIO matches the errors returned when the input can't be read. They also match the reader's own error with errors.Is and errors.As
+	ErrIO = errors.New("reading input")
+
+	//ErrMalformedLease matches the MalformedLeaseError recorded in Lease.Errors for each statement that can't be decoded. Malformed statements don't stop a parse, so it is only found in Lease.Errors
+	ErrMalformedLease = errors.New("malformed lease")
+
+	//ErrNoLease and ErrMultipleLeases are returned by ParseOne when its input doesn't hold exactly one lease block
+	ErrNoLease        = errors.New("no lease block in input")
+	ErrMultipleLeases = errors.New("more than one lease block in input")
+
+	//ErrNestedLease is recorded in Lease.Errors for a lease statement found inside another lease's block, and for a lease whose block is cut off by another block starting at the beginning of a line before the lease is closed. The statements of a nested block are skipped, and a block starting at the beginning of a line is read as a lease of its own
+	ErrNestedLease = errors.New("nested lease statement")
+
+	//ErrNoHardware is returned by ToHostDeclaration for a lease without a valid MAC address
+	ErrNoHardware = errors.New("lease has no valid hardware address")
+
+	utf8BOM = []byte{0xef, 0xbb, 0xbf}
+
+	leaseStartKeywords    = [][]byte{[]byte("\nlease ")}
+	failoverStartKeywords = [][]byte{[]byte("\nfailover peer ")}
 )
 
 /*
-Parse reads from a dhcpd.leases file and returns a list of leases.  Unknown fields are ignored
+ParseOptions controls the optional behaviour of ParseWithOptions.  The zero value parses the same
+way Parse does.
 */
-func Parse(r io.Reader) []Lease {
-	toLease := func(d []byte, atEOF bool) (advance int, token []byte, err error) {
+type ParseOptions struct {
+	//Offsets populates Lease.Offset and Lease.Length with the position of each lease block in the stream
+	Offsets bool
+
+	//OnUnknown is called with each statement in a lease block that has no decoder, such as a vendor specific field. Unknown statements are ignored when it is nil
+	OnUnknown func(lease *Lease, line string)
+
+	//Stats, if set, is filled in with statistics about the parse
+	Stats *ParseStats
+
+	//SkipIncompleteTail drops a partial block at the end of the input instead of returning ErrTruncated. dhcpd appends each lease to the file as it is committed, so a file read while dhcpd is writing to it can end part way through a lease; set this for live reads, and leave it unset for strict checks of a file at rest
+	SkipIncompleteTail bool
+
+	//AssumeActive gives leases without a binding state statement, as written by very old versions of dhcpd, the active binding state so IsActive goes by their end time alone. Lease.HasBindingState still reports the statement was missing
+	AssumeActive bool
+
+	//KeepRawTimes keeps each timestamp as written in Lease.StartsRaw and the like, for forensic checks such as comparing the recorded weekday with the date
+	KeepRawTimes bool
+
+	//MaxBlockSize is the largest lease block that can be parsed, in bytes. Larger blocks stop the parse with ErrTokenTooLarge. The default is bufio.MaxScanTokenSize, 64KB
+	MaxBlockSize int
+
+	//NormalizeCase lower cases Hardware.MAC, ClientHostname, OptionHostName, BindingState, NextBindingState, RewindBindingState and the names, but not the values, of Options, for matching without regard to case. Other fields are left as written
+	NormalizeCase bool
+
+	//Lenient accepts messy, such as hand edited, files: statements may be indented with tabs as well as spaces, tabs outside quotes count as spaces and space left at the end of a statement is trimmed. CRLF line endings, runs of spaces, '#' comments outside quotes and a closing brace on the same line as the last statement are accepted whether or not it is set
+	Lenient bool
+
+	//KeepComments keeps the '#' comments written in lease blocks in Lease.Comments. Lines holding only a comment are never counted as unknown statements, whether or not it is set
+	KeepComments bool
+
+	//Dialect is the flavour of dhcpd that wrote the input, DialectISC unless set
+	Dialect Dialect
+
+	//RecoverPartial returns a partial block at the end of the input, such as the last lease of a truncated file, as a lease with Partial set and whatever statements it has decoded, instead of returning ErrTruncated. A statement cut off part way through may decode to a partial value. It takes precedence over SkipIncompleteTail
+	RecoverPartial bool
+
+	//StripLinePrefix, if set, is removed from the start of every line it matches at before the input is parsed, such as the "Apr 27 03:24:45 host dhcpd[123]: " a log or journal export puts before each line of lease data. Lines it doesn't match are parsed as they are. Offsets are positions in the input with the prefixes removed
+	StripLinePrefix *regexp.Regexp
+}
+
+/*
+Dialect is a flavour of dhcpd lease file.  The formats share most statements, so every dialect is
+read with the same decoders and only the differences are handled per dialect.
+*/
+type Dialect int
+
+const (
+	//DialectISC is the format written by ISC dhcpd
+	DialectISC Dialect = iota
+
+	//DialectOpenBSD is the format written by OpenBSD's dhcpd, which indents with tabs and has no binding states or failover statements. Leases are given the active binding state unless marked abandoned, and the uid is written as a colon-separated hexadecimal list
+	DialectOpenBSD
+)
+
+/*
+ParseStats are aggregate statistics about a parse, for monitoring the health of ingestion
+*/
+type ParseStats struct {
+	//Blocks is the number of lease blocks seen, including any skipped
+	Blocks int
+
+	//Skipped is the number of lease blocks that couldn't be parsed and weren't returned, such as a truncated block at the end of the input
+	Skipped int
+
+	//Errors is the number of statements that failed to decode, as recorded in Lease.Errors
+	Errors int
+
+	//Unknown is the number of statements without a decoder
+	Unknown int
+
+	//Bytes is the number of bytes read from the input
+	Bytes int64
+}
+
+/*countingReader counts the bytes read through it*/
+type countingReader struct {
+	r io.Reader
+	n *int64
+}
+
+func (c countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	*c.n += int64(n)
+	return n, err
+}
+
+/*prefixStripReader removes the prefix matching re from the start of each line read from r*/
+type prefixStripReader struct {
+	r   *bufio.Reader
+	re  *regexp.Regexp
+	buf []byte
+	err error
+}
+
+func (s *prefixStripReader) Read(p []byte) (int, error) {
+	for len(s.buf) == 0 && s.err == nil {
+		var line []byte
+		line, s.err = s.r.ReadBytes('\n')
+		if loc := s.re.FindIndex(line); loc != nil && loc[0] == 0 {
+			line = line[loc[1]:]
+		}
+		s.buf = line
+	}
+	n := copy(p, s.buf)
+	s.buf = s.buf[n:]
+	if n == 0 {
+		return 0, s.err
+	}
+	return n, nil
+}
+
+/*
+blockEnd returns the index just past the '}' closing the block that starts at d[0], or the ';'
+ending it if it is a single statement such as "authoring-byte-order little-endian;". Braces inside
+quoted strings and comments are ignored and nested blocks are skipped over. found is false if d
+does not contain the end of the block yet.
+*/
+func blockEnd(d []byte) (end int, found bool) {
+	depth := 0
+	inQuotes := false
+	for j := 0; j < len(d); j++ {
+		switch {
+		case inQuotes && d[j] == '\\':
+			// skip over escaped characters
+			j++
+		case d[j] == '"':
+			inQuotes = !inQuotes
+		case inQuotes:
+		case d[j] == '#':
+			// comments run to the end of the line
+			if k := bytes.IndexByte(d[j:], '\n'); k != -1 {
+				j += k
+			} else {
+				return 0, false
+			}
+		case d[j] == ';' && depth == 0:
+			return j + 1, true
+		case d[j] == '{':
+			depth++
+		case d[j] == '}':
+			depth--
+			if depth <= 0 {
+				return j + 1, true
+			}
+		}
+	}
+	return 0, false
+}
+
+/*
+tokenizer splits a stream into blocks introduced by any of startKeywords, keeping track of how far into the
+stream each block starts.  Anything between blocks, such as comments or a stray ';' after a closing
+brace, is skipped.
+*/
+type tokenizer struct {
+	//startKeywords introduce the blocks to return, and include the preceding newline
+	startKeywords [][]byte
+
+	//offset is the number of bytes of the stream consumed so far
+	offset int64
+
+	//tokenOffset is the position in the stream of the last token returned
+	tokenOffset int64
+
+	//lineStart is set until something is consumed if the stream begins at the start of a line
+	lineStart bool
+
+	//maxSize is the largest block to buffer, or zero for bufio.Scanner's default
+	maxSize int
+
+	//recoverPartial returns an unterminated block at the end of the stream as the final token rather than failing with ErrTruncated
+	recoverPartial bool
+
+	//partial is set when the last token returned is such a block
+	partial bool
+
+	//gap collects the bytes skipped between blocks when set, for callers wanting the statements outside them
+	gap *bytes.Buffer
+}
+
+/*skip records b, skipped between blocks, in gap*/
+func (t *tokenizer) skip(b []byte) {
+	if t.gap != nil {
+		t.gap.Write(b)
+	}
+}
+
+/*newTokenizer returns a tokenizer for a stream that begins offset bytes into a file*/
+func newTokenizer(startKeywords [][]byte, offset int64) *tokenizer {
+	return &tokenizer{startKeywords: startKeywords, offset: offset, lineStart: offset == 0}
+}
+
+/*blockStart returns the index in d of the first block start, or -1 if there isn't one*/
+func (t *tokenizer) blockStart(d []byte) int {
+	for _, kw := range t.startKeywords {
+		if t.lineStart && bytes.HasPrefix(d, kw[1:]) {
+			// a block at the very start of the file has no newline before it
+			return 0
+		}
+	}
+	if i := t.nextKeyword(d); i != -1 {
+		return i + 1
+	}
+	return -1
+}
+
+/*nextKeyword returns the index in d of the newline introducing the first of startKeywords, or -1 if there isn't one*/
+func (t *tokenizer) nextKeyword(d []byte) int {
+	next := -1
+	for _, kw := range t.startKeywords {
+		if i := bytes.Index(d, kw); i != -1 && (next == -1 || i < next) {
+			next = i
+		}
+	}
+	return next
+}
+
+/*maxKeywordLen returns the length of the longest of startKeywords*/
+func (t *tokenizer) maxKeywordLen() int {
+	n := 0
+	for _, kw := range t.startKeywords {
+		if len(kw) > n {
+			n = len(kw)
+		}
+	}
+	return n
+}
+
+func (t *tokenizer) split(d []byte, atEOF bool) (advance int, token []byte, err error) {
+	// the tokenizer also backs ScanLeases, so don't build log fields unless they will be used
+	trace := log.IsLevelEnabled(log.TraceLevel)
+	if trace {
 		log.WithFields(log.Fields{"leaseEOF": atEOF}).Trace("EOF Check")
+	}
+	if t.lineStart && len(d) > 0 && d[0] == utf8BOM[0] {
+		// some tools put a byte order mark at the start of the file, before the first lease
+		if bytes.HasPrefix(d, utf8BOM) {
+			t.offset += int64(len(utf8BOM))
+			return len(utf8BOM), nil, nil
+		}
+		if !atEOF && len(d) < len(utf8BOM) {
+			return 0, nil, nil
+		}
+	}
+
+	start := t.blockStart(d)
+	if start == -1 {
 		if atEOF {
-			return 0, nil, fmt.Errorf("unable to parse")
-		}
-		if i := bytes.Index(d, leaseStartKeyword); i != -1 {
-			log.WithFields(log.Fields{"leaseBegin": i}).Trace("Found lease start")
-			i += 1
-			inQuotes := false
-			// locate following "}"
-			for j := i; j < len(d); j++ {
-				// skip over escaped characters
-				if d[j] == '\\' && j+1 < len(d) && (d[j+1] == '"' || d[j+1] == '\\') {
-					j++
-					continue
-				}
-				if d[j] == '"' {
-					inQuotes = !inQuotes
-					continue
-				}
-
-				end := j + len(leaseEndKeyword)
-				if !inQuotes && end < len(d) && bytes.Compare(d[j:end], leaseEndKeyword) == 0 {
-					log.WithFields(log.Fields{"leaseEnd": j}).Trace("Found lease end")
-					return j + 1, d[i : j+1], nil
-				}
+			if t.gap != nil && len(d) > 0 {
+				t.skip(d)
+				t.offset += int64(len(d))
+				return len(d), nil, nil
 			}
+			return 0, nil, nil
+		}
+		// nothing before the last few bytes can start a block so don't keep buffering it
+		if skip := len(d) - t.maxKeywordLen() + 1; skip > 0 {
+			t.skip(d[:skip])
+			t.lineStart = false
+			t.offset += int64(skip)
+			return skip, nil, nil
 		}
 		return 0, nil, nil
 	}
 
-	log.Trace("Starting scanner")
-	scanner := bufio.NewScanner(r)
-	scanner.Split(toLease)
+	if trace {
+		log.WithFields(log.Fields{"leaseBegin": start}).Trace("Found block start")
+	}
+	// a block start at the beginning of a line inside the block means it was never closed, as when
+	// a corrupt file has a lease written inside another, so the block ends there and the blocks
+	// after it are still read
+	block := d[start:]
+	next := t.nextKeyword(block)
+	if next != -1 {
+		block = block[:next]
+	}
+	if end, found := blockEnd(block); found || next != -1 {
+		if !found {
+			end = next
+		}
+		if trace {
+			log.WithFields(log.Fields{"leaseEnd": start + end}).Trace("Found block end")
+		}
+		t.skip(d[:start])
+		t.lineStart = false
+		t.tokenOffset = t.offset + int64(start)
+		t.offset += int64(start + end)
+		return start + end, d[start : start+end], nil
+	}
+	if atEOF {
+		if t.recoverPartial {
+			t.partial = true
+			t.tokenOffset = t.offset + int64(start)
+			t.offset += int64(len(d))
+			return len(d), d[start:], bufio.ErrFinalToken
+		}
+		return 0, nil, ErrTruncated
+	}
+	if start > 1 {
+		// drop anything before the block, apart from the newline introducing it, while waiting for
+		// the rest of it
+		t.skip(d[:start-1])
+		t.lineStart = false
+		t.offset += int64(start - 1)
+		return start - 1, nil, nil
+	}
+	return 0, nil, nil
+}
+
+/*
+ScanLeases calls fn with the byte range data[start:end] of each complete lease block in data,
+without decoding them, so callers can pick out and decode only the blocks they need.  Scanning stops
+at the first incomplete block.
+*/
+func ScanLeases(data []byte, fn func(start, end int)) {
+	t := tokenizer{startKeywords: leaseStartKeywords, lineStart: true}
+	for pos := 0; pos < len(data); {
+		advance, token, err := t.split(data[pos:], true)
+		if err != nil || advance == 0 {
+			return
+		}
+		if token != nil {
+			start := int(t.tokenOffset)
+			fn(start, start+len(token))
+		}
+		pos += advance
+	}
+}
+
+/*
+Parse reads from a dhcpd.leases file and returns a list of leases.  Unknown fields are ignored
+*/
+func Parse(r io.Reader) []Lease {
+	leases, _ := ParseWithOptions(r, ParseOptions{})
+	return leases
+}
+
+/*
+ParseWithOptions reads from a dhcpd.leases file and returns a list of leases, parsed according to
+opts.  Unknown fields are ignored.  An error matching ErrIO is returned if r could not be read,
+ErrTruncated if it ends part way through a lease and opts.SkipIncompleteTail is unset, or
+ErrTokenTooLarge if a lease is larger than opts.MaxBlockSize.  The leases parsed up to that point are still returned.
+*/
+func ParseWithOptions(r io.Reader, opts ParseOptions) ([]Lease, error) {
+	var rtn []Lease
+
+	err := parseLeases(r, opts, func(l Lease) {
+		rtn = append(rtn, l)
+	})
+	return rtn, err
+}
+
+/*
+ParseBytes parses the leases in b, the contents of a dhcpd.leases file, as ParseWithOptions does
+*/
+func ParseBytes(b []byte) ([]Lease, error) {
+	return ParseWithOptions(bytes.NewReader(b), ParseOptions{})
+}
 
+/*
+ParseRegion parses the complete lease blocks in data, a window onto a larger dhcpd.leases file such
+as part of a memory mapped file, without going through a reader.  A partial block at the start or
+end of the window is silently dropped, so windows should overlap by at least a block to see every
+lease.  Offset and Length are populated, with offsets relative to the start of data.
+*/
+func ParseRegion(data []byte) []Lease {
 	var rtn []Lease
+	opts := ParseOptions{Offsets: true}
+	ScanLeases(data, func(start, end int) {
+		rtn = append(rtn, parseLeaseBlock(data[start:end], int64(start), false, &opts))
+	})
+	return rtn
+}
+
+/*
+ParseOne parses b as a single lease block, such as a lease sent one per message on a queue.
+ErrNoLease or ErrMultipleLeases is returned if b doesn't hold exactly one lease block, and
+ErrTruncated if the block isn't closed.
+*/
+func ParseOne(b []byte) (Lease, error) {
+	leases, err := ParseWithOptions(bytes.NewReader(b), ParseOptions{})
+	switch {
+	case err != nil:
+		return Lease{}, err
+	case len(leases) == 0:
+		return Lease{}, ErrNoLease
+	case len(leases) > 1:
+		return Lease{}, ErrMultipleLeases
+	}
+	return leases[0], nil
+}
+
+/*
+ParseAll reads from a dhcpd.leases file in a single pass, returning both the current lease for each
+IP and the full history of lease records in the order they appear in the file.  dhcpd appends a
+new record each time a lease changes, so the last record for an IP is the current one.
+*/
+func ParseAll(r io.Reader) (current map[string]Lease, history []Lease, err error) {
+	current = map[string]Lease{}
+
+	err = parseLeases(r, ParseOptions{}, func(l Lease) {
+		current[l.Key()] = l
+		history = append(history, l)
+	})
+	return current, history, err
+}
+
+/*
+ParseCurrent reads from a dhcpd.leases file and returns the current lease for each IP, as
+CurrentByIP does, without keeping the full list of leases as ParseAll does.  Each lease replaces
+any earlier one for its IP as it is parsed, which costs no more than adding it to the map, so files
+already holding only current leases need no separate handling.  Errors are returned as for
+ParseWithOptions, along with the leases read up to that point.
+*/
+func ParseCurrent(r io.Reader, opts ParseOptions) (map[string]Lease, error) {
+	current := map[string]Lease{}
+	err := parseLeases(r, opts, func(l Lease) {
+		current[l.Key()] = l
+	})
+	return current, err
+}
+
+/*
+ParseFrom reads leases from r, which is positioned startOffset bytes into a dhcpd.leases file, such
+as just past the last lease read from a file that has since grown.  Anything before the first line
+starting a lease is a partial block and is skipped.  Offset and Length are always populated, with
+offsets relative to the start of the file.
+*/
+func ParseFrom(r io.Reader, startOffset int64) ([]Lease, error) {
+	var rtn []Lease
+
+	t := newTokenizer(leaseStartKeywords, startOffset)
+	err := parseBlocks(r, t, ParseOptions{Offsets: true}, func(l Lease) {
+		rtn = append(rtn, l)
+	})
+	return rtn, err
+}
+
+/*Cursor is the position ParseResume has read a dhcpd.leases file up to*/
+type Cursor struct {
+	//Offset is the position in bytes just past the last complete lease block read
+	Offset int64
+}
+
+/*
+ParseResume reads the leases appended to r since the last call with cur, for re-reading a file dhcpd
+is appending to without parsing all of it each time.  It seeks to cur.Offset and parses the complete
+lease blocks from there, moving cur.Offset to the end of the last of them.  A partial block at the
+end, such as a lease dhcpd is still writing, isn't returned and cur isn't moved past it, so it is
+read in full by a later call.  If r is now shorter than cur.Offset, the file has been replaced or
+truncated and is read again from the start.  Offset and Length are populated as for ParseFrom.
+*/
+func ParseResume(r io.ReadSeeker, cur *Cursor) ([]Lease, error) {
+	size, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	if size < cur.Offset {
+		cur.Offset = 0
+	}
+	if _, err := r.Seek(cur.Offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	leases, err := ParseFrom(r, cur.Offset)
+	if n := len(leases); n > 0 {
+		cur.Offset = leases[n-1].Offset + int64(leases[n-1].Length)
+	}
+	if err == ErrTruncated {
+		return leases, nil
+	}
+	return leases, err
+}
+
+/*parseLeases calls fn with each lease parsed from r*/
+func parseLeases(r io.Reader, opts ParseOptions, fn func(Lease)) error {
+	return parseBlocks(r, newTokenizer(leaseStartKeywords, 0), opts, fn)
+}
+
+/*parseBlocks calls fn with each lease parsed from the blocks t finds in r*/
+func parseBlocks(r io.Reader, t *tokenizer, opts ParseOptions, fn func(Lease)) error {
+	return parseMixedBlocks(r, t, opts, fn, nil)
+}
+
+/*
+parseMixedBlocks calls fn with each lease parsed from the lease blocks t finds in r, and other, if
+set, with each of the other blocks
+*/
+func parseMixedBlocks(r io.Reader, t *tokenizer, opts ParseOptions, fn func(Lease), other func(block []byte)) error {
+	if opts.Stats != nil {
+		r = countingReader{r: r, n: &opts.Stats.Bytes}
+	}
+	if opts.StripLinePrefix != nil {
+		r = &prefixStripReader{r: bufio.NewReader(r), re: opts.StripLinePrefix}
+	}
+	t.maxSize = opts.MaxBlockSize
+	t.recoverPartial = opts.RecoverPartial
+
+	err := scanBlocks(r, t, func(block []byte) {
+		if other != nil && !bytes.HasPrefix(block, []byte("lease ")) {
+			other(block)
+			return
+		}
+		l := parseLeaseBlock(block, t.tokenOffset, t.partial, &opts)
+		log.WithFields(log.Fields{
+			"lease": l,
+		}).Trace("Parsed lease")
+		fn(l)
+	})
+	if err == ErrTruncated {
+		if opts.Stats != nil {
+			opts.Stats.Blocks++
+			opts.Stats.Skipped++
+		}
+		if opts.SkipIncompleteTail {
+			log.Debug("Skipping incomplete lease at end of input")
+			return nil
+		}
+	}
+	return err
+}
+
+/*
+parseLeaseBlock parses a lease block found offset bytes into the stream, applying the options that
+act on the whole lease once its statements are decoded.  partial is set for a block cut off by the
+end of the input.
+*/
+func parseLeaseBlock(block []byte, offset int64, partial bool, opts *ParseOptions) Lease {
+	l := Lease{Partial: partial}
+	l.parse(block, opts)
+	if !partial && !bytes.HasSuffix(bytes.TrimRight(block, " \t\r\n"), []byte("}")) {
+		first, _ := nextLine(block)
+		l.addError(first, fmt.Errorf("%w: lease for %s isn't closed before the next block", ErrNestedLease, l.RawAddress))
+	}
+	for _, err := range l.Errors {
+		if m, ok := err.(*MalformedLeaseError); ok {
+			m.Offset = offset
+		}
+	}
+	if opts.NormalizeCase {
+		l.normalizeCase()
+	}
+	if (opts.AssumeActive || opts.Dialect == DialectOpenBSD) && l.BindingState == "" {
+		l.BindingState = "active"
+		l.assumedState = true
+	}
+	if opts.Offsets {
+		l.Offset = offset
+		l.Length = len(block)
+	}
+	if opts.Stats != nil {
+		opts.Stats.Blocks++
+		opts.Stats.Errors += len(l.Errors)
+	}
+	return l
+}
+
+/*scanBlocks calls fn with each block t finds in r*/
+func scanBlocks(r io.Reader, t *tokenizer, fn func(block []byte)) error {
+	log.Trace("Starting scanner")
+	scanner := bufio.NewScanner(ioErrorReader{r})
+	scanner.Split(t.split)
+	if t.maxSize > 0 {
+		scanner.Buffer(nil, t.maxSize)
+	}
 
 	log.Trace("Scanning over tokens")
 	for scanner.Scan() {
-		l := Lease{}
 		scannerBytes := scanner.Bytes()
 		log.WithFields(log.Fields{
 			"scannerBytes": scannerBytes,
 		}).Trace("Got bytes from scanner")
-		l.parse(scannerBytes)
-		log.WithFields(log.Fields{
-			"lease": l,
-		}).Trace("Parsed lease")
-		rtn = append(rtn, l)
-
+		fn(scannerBytes)
 	}
 	log.Trace("Scanning complete")
-	return rtn
+	return scanner.Err()
 }
@@ -1,13 +1,24 @@
 package leases
 
 import (
+	"bufio"
 	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
 	"testing"
+	"testing/iotest"
 	"time"
 )
 
-func TestParseLease(t *testing.T) {
-	in := []byte(`# The format of this file is documented in the dhcpd.leases(5) manual page.
+// fixtures shared with FuzzParse
+const (
+	fileFixture = `# The format of this file is documented in the dhcpd.leases(5) manual page.
 # This lease file was written by isc-dhcp-4.3.6-P1
 
 # authoring-byte-order entry is generated, DO NOT DELETE
@@ -28,31 +39,9 @@ lease 172.24.43.3 {
 }
 lease 172.24.43.4 {
 
-`)
-
-	buf := bytes.NewBuffer(in)
-	i := Parse(buf)
-	if i == nil {
-		t.Errorf("Expect one lease")
-	}
-}
-
-func TestParse(t *testing.T) {
-	a := parseTime("cltt 6 2019/04/27 03:34:45;")
-	ex := time.Date(2019, 4, 27, 3, 34, 45, 0, time.UTC)
-
-	if a.IsZero() {
-		t.Error("Didnt parse time right")
-	}
-	if !a.Equal(ex) {
-		t.Log("a ", a)
-		t.Log("ex", ex)
-		t.Error("Didnt parse time correctly")
-	}
-}
+`
 
-func TestParseWithBrace(t *testing.T) {
-	leaseData := `
+	braceFixture = `
 lease 172.16.0.60 {
   starts 4 2022/03/31 15:52:00;
   ends 4 2022/03/31 19:52:00;
@@ -88,28 +77,8 @@ lease 172.16.0.219 {
   client-hostname "vmubt2004kube02";
 }
 `
-	want := [][]string{
-		{"172.16.0.60", "m8"},
-		{"172.16.0.67", "vmubt2004kube01"},
-		{"172.16.0.219", "vmubt2004kube02"},
-	}
-
-	buf := bytes.NewBufferString(leaseData)
 
-	leases := Parse(buf)
-
-	for i, data := range want {
-		if leases[i].IP.String() != data[0] {
-			t.Errorf("%v should have IP %s", leases[i], data[0])
-		}
-		if leases[i].ClientHostname != data[1] {
-			t.Errorf("%v should have hostname %s", leases[i], data[1])
-		}
-	}
-}
-
-func TestParseLeaseUidWithQuote(t *testing.T) {
-	leaseData := `
+	uidQuoteFixture = `
 lease 172.16.0.66 {
   starts 4 2022/03/31 18:29:06;
   ends 4 2022/03/31 22:29:06;
@@ -134,10 +103,354 @@ lease 172.16.0.24 {
   client-hostname "DESKTOP-2AFSHAA";
 }
 `
+
+	compactFixture = `
+lease 172.16.0.60 {
+  starts 4 2022/03/31 15:52:00;
+  ends 4 2022/03/31 19:52:00;
+  binding state active;
+  hardware ethernet 00:00:00:00:00:01;
+  client-hostname "m8"; }
+lease 172.16.0.61 {
+  starts 4 2022/03/31 15:53:00;
+  binding state active;
+  uid "\001{}\"}";
+  client-hostname "brace}"; }
+lease 172.16.0.62 {
+  binding state free;
+  client-hostname "last";
+}
+`
+
+	junkFixture = `
+lease 172.16.0.60 {
+  binding state active;
+};
+;
+   
+# lease 172.16.0.99 {
+	
+lease 172.16.0.61 {
+  binding state free;
+}  ;  
+lease 172.16.0.62 {
+  binding state backup;
+}
+;`
+
+	openbsdFixture = "lease 192.168.1.100 {\n" +
+		"\tstarts 2 2020/01/07 10:00:00;\n" +
+		"\tends 3 2020/01/08 10:00:00;\n" +
+		"\thardware ethernet 00:db:70:c3:11:d7;\n" +
+		"\tuid 01:00:db:70:c3:11:d7;\n" +
+		"\tclient-hostname \"laptop\";\n" +
+		"}\n" +
+		"lease 192.168.1.101 {\n" +
+		"\tstarts 2 2020/01/07 11:00:00;\n" +
+		"\tends 3 2020/01/08 11:00:00;\n" +
+		"\thardware ethernet 00:db:70:c3:11:d8;\n" +
+		"\tdynamic-bootp;\n" +
+		"\tabandoned;\n" +
+		"}\n"
+)
+
+func TestParseLease(t *testing.T) {
+	in := []byte(fileFixture)
+
+	buf := bytes.NewBuffer(in)
+	i := Parse(buf)
+	if i == nil {
+		t.Errorf("Expect one lease")
+	}
+
+	// the fixture is indented with tabs
+	leases, _ := ParseWithOptions(bytes.NewBuffer(in), ParseOptions{Lenient: true})
+	if len(leases) == 0 {
+		t.Fatal("Expect one lease")
+	}
+	if want := []byte{0x01, 0x00, 0xdb, 0x70, 0xc3, 0x11, 0xd7}; !bytes.Equal(leases[0].UIDBytes, want) {
+		t.Errorf("uid %q decoded to %#v, expected %#v", leases[0].UID, leases[0].UIDBytes, want)
+	}
+}
+
+func TestParse(t *testing.T) {
+	a := parseTime("cltt 6 2019/04/27 03:34:45;")
+	ex := time.Date(2019, 4, 27, 3, 34, 45, 0, time.UTC)
+
+	if a.IsZero() {
+		t.Error("Didnt parse time right")
+	}
+	if !a.Equal(ex) {
+		t.Log("a ", a)
+		t.Log("ex", ex)
+		t.Error("Didnt parse time correctly")
+	}
+}
+
+func TestParseTimeWeekday(t *testing.T) {
+	ex := time.Date(2019, 4, 27, 3, 34, 45, 0, time.UTC)
+
+	for _, line := range []string{
+		"ends 6 2019/04/27 03:34:45;",
+		"ends 2019/04/27 03:34:45;",
+	} {
+		if a := parseTime(line); !a.Equal(ex) {
+			t.Errorf("%q parsed as %v, expected %v", line, a, ex)
+		}
+	}
+
+	for _, line := range []string{
+		"ends 7 2019/04/27 03:34:45;",
+		"ends 12 2019/04/27 03:34:45;",
+	} {
+		if a := parseTime(line); !a.IsZero() {
+			t.Errorf("%q has an invalid weekday and shouldn't parse, got %v", line, a)
+		}
+	}
+
+	leaseData := `
+lease 172.16.0.60 {
+  starts 6 2019/04/27 03:24:45;
+  ends 6 2019/04/27 03:34:45;
+}
+lease 172.16.0.61 {
+  starts 2019/04/27 03:24:45;
+  ends 2019/04/27 03:34:45;
+}
+`
+	for _, l := range Parse(bytes.NewBufferString(leaseData)) {
+		if !l.Ends.Equal(ex) {
+			t.Errorf("%s ends %v, expected %v", l.IP, l.Ends, ex)
+		}
+		if d := l.Ends.Sub(l.Starts); d != 10*time.Minute {
+			t.Errorf("%s lasts %v, expected 10m", l.IP, d)
+		}
+	}
+}
+
+func TestParseTimeNever(t *testing.T) {
+	leaseData := `
+lease 172.16.0.60 {
+  tstp 0 never;
+  tsfp never;
+  atsfp 6 2019/04/27 03:34:45;
+}
+`
+	leases := Parse(bytes.NewBufferString(leaseData))
+	if len(leases) != 1 {
+		t.Fatalf("found %d leases, expected 1", len(leases))
+	}
+
+	l := leases[0]
+	if !l.Tstp.Equal(Never) {
+		t.Errorf("tstp with a weekday before never is %v, expected Never", l.Tstp)
+	}
+	if !l.Tsfp.Equal(Never) {
+		t.Errorf("tsfp never is %v, expected Never", l.Tsfp)
+	}
+	if want := time.Date(2019, 4, 27, 3, 34, 45, 0, time.UTC); !l.Atsfp.Equal(want) {
+		t.Errorf("atsfp %v, expected %v", l.Atsfp, want)
+	}
+
+	for _, line := range []string{"tstp never", "tstp 0 never", "tstp", "tstp ;", "tstp 0;"} {
+		// malformed variants mustn't panic
+		parseTime(line)
+	}
+}
+
+func TestParseTimeFractional(t *testing.T) {
+	leaseData := `
+lease 172.16.0.60 {
+  starts 6 2019/04/27 03:24:45;
+  tstp 6 2019/04/27 03:34:45.123;
+  tsfp 6 2019/04/27 03:34:45.123456;
+}
+`
+	leases := Parse(bytes.NewBufferString(leaseData))
+	if len(leases) != 1 {
+		t.Fatalf("found %d leases, expected 1", len(leases))
+	}
+
+	l := leases[0]
+	if want := time.Date(2019, 4, 27, 3, 24, 45, 0, time.UTC); !l.Starts.Equal(want) {
+		t.Errorf("starts %v, expected %v", l.Starts, want)
+	}
+	if want := time.Date(2019, 4, 27, 3, 34, 45, 123000000, time.UTC); !l.Tstp.Equal(want) {
+		t.Errorf("tstp %v, expected %v", l.Tstp, want)
+	}
+	if want := time.Date(2019, 4, 27, 3, 34, 45, 123456000, time.UTC); !l.Tsfp.Equal(want) {
+		t.Errorf("tsfp %v, expected %v", l.Tsfp, want)
+	}
+}
+
+func TestParseTimeEpoch(t *testing.T) {
+	leaseData := `
+lease 172.16.0.60 {
+  starts epoch 1556335485; # Sat Apr 27 03:24:45 2019
+  ends epoch 1556336085; # Sat Apr 27 03:34:45 2019
+  cltt 6 2019/04/27 03:24:45;
+  binding state active;
+}
+lease 172.16.0.61 {
+  starts 6 2019/04/27 03:24:45;
+  ends 6 2019/04/27 03:34:45;
+  binding state active;
+}
+`
+	leases := Parse(bytes.NewBufferString(leaseData))
+	if len(leases) != 2 {
+		t.Fatalf("found %d leases, expected 2", len(leases))
+	}
+
+	starts := time.Date(2019, 4, 27, 3, 24, 45, 0, time.UTC)
+	ends := time.Date(2019, 4, 27, 3, 34, 45, 0, time.UTC)
+	for i, l := range leases {
+		if !l.Starts.Equal(starts) || !l.Ends.Equal(ends) {
+			t.Errorf("lease %d runs %v to %v, expected %v to %v", i, l.Starts, l.Ends, starts, ends)
+		}
+	}
+	if !leases[0].Cltt.Equal(starts) {
+		t.Errorf("cltt %v, expected %v", leases[0].Cltt, starts)
+	}
+}
+
+func TestParseFixedDate(t *testing.T) {
+	inputs := []string{
+		"2019/04/27 03:24:45",
+		"2020/02/29 23:59:59",
+		"1970/01/01 00:00:00",
+		"2019/02/29 00:00:00",
+		"2019/13/01 00:00:00",
+		"2019/04/31 00:00:00",
+		"2019/04/27 24:00:00",
+		"2019/04/27 03:60:45",
+		"2019/04/27 03:24:60",
+		"2019/04/27 03:24:45.5",
+		"2019-04-27 03:24:45",
+		"2019/04/27 3:24:45",
+		"2019/0a/27 03:24:45",
+		"",
+	}
+	for _, s := range inputs {
+		want, err := time.Parse("2006/01/02 15:04:05", s)
+		got := parseDate(s)
+		if !got.Equal(want) {
+			t.Errorf("%q parsed as %v, expected %v", s, got, want)
+		}
+		if _, ok := parseFixedDate(s); ok && err != nil {
+			t.Errorf("%q accepted by the fast path but rejected by time.Parse", s)
+		}
+	}
+
+	if n := testing.AllocsPerRun(100, func() { parseDate("6 2019/04/27 03:24:45") }); n != 0 {
+		t.Errorf("parseDate allocated %v times, expected none", n)
+	}
+}
+
+func BenchmarkParseDate(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		parseDate("6 2019/04/27 03:24:45")
+	}
+}
+
+func BenchmarkParseDateTimeParse(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		time.Parse("2006/01/02 15:04:05", "2019/04/27 03:24:45")
+	}
+}
+
+func TestParseCurrent(t *testing.T) {
+	leaseData := `
+lease 172.16.0.60 {
+  binding state active;
+}
+lease 172.16.0.61 {
+  binding state active;
+}
+lease 172.16.0.60 {
+  binding state free;
+}
+`
+	current, err := ParseCurrent(bytes.NewBufferString(leaseData), ParseOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(current) != 2 || current["172.16.0.60"].BindingState != "free" || current["172.16.0.61"].BindingState != "active" {
+		t.Errorf("unexpected current leases %v", current)
+	}
+}
+
+/*dedupedFixture returns a file of n leases, each for a different IP*/
+func dedupedFixture(n int) []byte {
+	var b bytes.Buffer
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, "lease 10.%d.%d.%d {\n  starts 6 2019/04/27 03:24:45;\n  ends 6 2019/04/27 03:34:45;\n  binding state active;\n  hardware ethernet 00:db:70:%02x:%02x:%02x;\n}\n",
+			i>>16&0xff, i>>8&0xff, i&0xff, i>>16&0xff, i>>8&0xff, i&0xff)
+	}
+	return b.Bytes()
+}
+
+func BenchmarkParseDeduplicated(b *testing.B) {
+	data := dedupedFixture(20000)
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		ParseWithOptions(bytes.NewReader(data), ParseOptions{})
+	}
+}
+
+func BenchmarkParseCurrent(b *testing.B) {
+	data := dedupedFixture(20000)
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		ParseCurrent(bytes.NewReader(data), ParseOptions{})
+	}
+}
+
+func TestParseWithBrace(t *testing.T) {
+	leaseData := braceFixture
+	want := [][]string{
+		{"172.16.0.60", "m8"},
+		{"172.16.0.67", "vmubt2004kube01"},
+		{"172.16.0.219", "vmubt2004kube02"},
+	}
+	wantUIDs := [][]byte{
+		{0x01, 0x00, 0xee, 0xbd, 0xb4, 0xbe, 0x6a},
+		{0xff, 0x76, 0x5f, 0x7d, 0x8a, 0x00, 0x02, 0x00, 0x00, 0xab, 0x11, 0x41, 0x0d, 0x10, 0x2c, 0x4a, 0xbd, 0x62, 0x5c},
+		{0xff, 0x37, 0xfc, 0x10, 0x88, 0x00, 0x02, 0x00, 0x00, 0xab, 0x11, 0x41, 0x0d, 0x10, 0x2c, 0x4a, 0xbd, 0x62, 0x5c},
+	}
+
+	buf := bytes.NewBufferString(leaseData)
+
+	leases := Parse(buf)
+
+	for i, data := range want {
+		if leases[i].IP.String() != data[0] {
+			t.Errorf("%v should have IP %s", leases[i], data[0])
+		}
+		if leases[i].ClientHostname != data[1] {
+			t.Errorf("%v should have hostname %s", leases[i], data[1])
+		}
+		if !bytes.Equal(leases[i].UIDBytes, wantUIDs[i]) {
+			t.Errorf("%s uid decoded to %#v, expected %#v", data[0], leases[i].UIDBytes, wantUIDs[i])
+		}
+	}
+}
+
+func TestParseLeaseUidWithQuote(t *testing.T) {
+	leaseData := uidQuoteFixture
 	want := [][]string{
 		{"172.16.0.66", "vmubt2004kube04"},
 		{"172.16.0.24", "DESKTOP-2AFSHAA"},
 	}
+	// escaped quotes and backslashes don't end the uid
+	wantUIDs := [][]byte{
+		{0xff, 0x22, 0xc5, 0x82, 0xe7, 0x00, 0x02, 0x00, 0x00, 0xab, 0x11, 0x41, 0x0d, 0x10, 0x2c, 0x4a, 0xbd, 0x62, 0x5c},
+		{0x01, 0x34, 0xf6, 0x4b, 0x63, 0x5c, 0x45},
+	}
 
 	buf := bytes.NewBufferString(leaseData)
 
@@ -155,5 +468,1201 @@ lease 172.16.0.24 {
 		if leases[i].ClientHostname != data[1] {
 			t.Errorf("%v should have hostname %s", leases[i], data[1])
 		}
+		if !bytes.Equal(leases[i].UIDBytes, wantUIDs[i]) {
+			t.Errorf("%s uid decoded to %#v, expected %#v", data[0], leases[i].UIDBytes, wantUIDs[i])
+		}
+	}
+}
+
+func TestParseCompactBrace(t *testing.T) {
+	leaseData := compactFixture
+	want := [][]string{
+		{"172.16.0.60", "m8"},
+		{"172.16.0.61", "brace}"},
+		{"172.16.0.62", "last"},
+	}
+	wantUIDs := [][]byte{nil, {0x01, '{', '}', '"', '}'}, nil}
+
+	leases := Parse(bytes.NewBufferString(leaseData))
+
+	if len(leases) != len(want) {
+		t.Fatalf("found %d leases, expected %d", len(leases), len(want))
+	}
+
+	for i, data := range want {
+		if leases[i].IP.String() != data[0] {
+			t.Errorf("%v should have IP %s", leases[i], data[0])
+		}
+		if leases[i].ClientHostname != data[1] {
+			t.Errorf("%v should have hostname %s", leases[i], data[1])
+		}
+		if leases[i].BindingState == "" {
+			t.Errorf("%v should have a binding state", leases[i])
+		}
+		if !bytes.Equal(leases[i].UIDBytes, wantUIDs[i]) {
+			t.Errorf("%s uid decoded to %#v, expected %#v", data[0], leases[i].UIDBytes, wantUIDs[i])
+		}
+	}
+}
+
+func TestParseOffsets(t *testing.T) {
+	leaseData := `authoring-byte-order little-endian;
+
+lease 172.16.0.60 {
+  starts 4 2022/03/31 15:52:00;
+  client-hostname "m8";
+}
+lease 172.16.0.67 {
+  starts 4 2022/03/31 16:27:59;
+  uid "\377v_}\212\000\002\000\000\253\021A\015\020,J\275b\\";
+  client-hostname "vmubt2004kube01";
+}
+`
+	leases, err := ParseWithOptions(bytes.NewBufferString(leaseData), ParseOptions{Offsets: true})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(leases) != 2 {
+		t.Fatalf("found %d leases, expected 2", len(leases))
+	}
+
+	for _, l := range leases {
+		block := leaseData[l.Offset : l.Offset+int64(l.Length)]
+		if !strings.HasPrefix(block, "lease "+l.IP.String()+" {") || !strings.HasSuffix(block, "}") {
+			t.Errorf("offset %d length %d doesn't point at lease %s: %q", l.Offset, l.Length, l.IP, block)
+		}
+	}
+
+	for _, l := range Parse(bytes.NewBufferString(leaseData)) {
+		if l.Offset != 0 || l.Length != 0 {
+			t.Errorf("%v shouldn't have an offset without the option", l)
+		}
+	}
+}
+
+func TestParseAll(t *testing.T) {
+	leaseData := `
+lease 172.16.0.60 {
+  starts 4 2022/03/31 15:52:00;
+  binding state active;
+  client-hostname "first";
+}
+lease 172.16.0.61 {
+  starts 4 2022/03/31 15:53:00;
+  binding state active;
+  client-hostname "other";
+}
+lease 172.16.0.60 {
+  starts 4 2022/03/31 16:52:00;
+  binding state free;
+  client-hostname "second";
+}
+`
+	current, history, err := ParseAll(bytes.NewBufferString(leaseData))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	wantHistory := []string{"first", "other", "second"}
+	if len(history) != len(wantHistory) {
+		t.Fatalf("found %d history records, expected %d", len(history), len(wantHistory))
+	}
+	for i, name := range wantHistory {
+		if history[i].ClientHostname != name {
+			t.Errorf("history record %d should be %s, got %s", i, name, history[i].ClientHostname)
+		}
+	}
+
+	if len(current) != 2 {
+		t.Fatalf("found %d current leases, expected 2", len(current))
+	}
+	if l := current["172.16.0.60"]; l.ClientHostname != "second" || l.BindingState != "free" {
+		t.Errorf("%v should be the latest record for 172.16.0.60", l)
+	}
+	if l := current["172.16.0.61"]; l.ClientHostname != "other" {
+		t.Errorf("%v should be the only record for 172.16.0.61", l)
+	}
+}
+
+func TestParseUIDBytes(t *testing.T) {
+	leaseData := `
+lease 172.16.0.60 {
+  uid "\001\000\356\275\264\276j";
+}
+lease 172.16.0.61 {
+  uid 01:00:ee:bd:b4:be:6a;
+}
+`
+	want := []byte{0x01, 0x00, 0xee, 0xbd, 0xb4, 0xbe, 'j'}
+	wantUID := []string{`\001\000\356\275\264\276j`, "01:00:ee:bd:b4:be:6a"}
+
+	leases := Parse(bytes.NewBufferString(leaseData))
+	if len(leases) != 2 {
+		t.Fatalf("found %d leases, expected 2", len(leases))
+	}
+	for i, l := range leases {
+		if l.UID != wantUID[i] {
+			t.Errorf("%s has UID %q, expected %q", l.IP, l.UID, wantUID[i])
+		}
+		if !bytes.Equal(l.UIDBytes, want) {
+			t.Errorf("%s has UID bytes %v, expected %v", l.IP, l.UIDBytes, want)
+		}
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	for _, seed := range []string{fileFixture, braceFixture, uidQuoteFixture, compactFixture, junkFixture, openbsdFixture} {
+		f.Add([]byte(seed))
+	}
+
+	f.Fuzz(func(t *testing.T, in []byte) {
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			ParseWithOptions(bytes.NewReader(in), ParseOptions{Offsets: true})
+		}()
+
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("parse didn't finish for %q", in)
+		}
+	})
+}
+
+func TestParseUnterminated(t *testing.T) {
+	leaseData := `
+lease 172.16.0.60 {
+  binding state active;
+}
+lease 172.16.0.61 {
+  binding state active;
+  client-hostname "trunc`
+
+	leases, err := ParseWithOptions(bytes.NewBufferString(leaseData), ParseOptions{})
+	if !errors.Is(err, ErrTruncated) {
+		t.Errorf("expected ErrTruncated, got %v", err)
+	}
+	if len(leases) != 1 || leases[0].IP.String() != "172.16.0.60" {
+		t.Errorf("expected the complete lease before the truncated one, got %v", leases)
+	}
+
+	var stats ParseStats
+	leases, err = ParseWithOptions(bytes.NewBufferString(leaseData), ParseOptions{SkipIncompleteTail: true, Stats: &stats})
+	if err != nil {
+		t.Errorf("expected the truncated lease to be skipped, got %v", err)
+	}
+	if len(leases) != 1 || stats.Skipped != 1 {
+		t.Errorf("expected 1 lease and 1 skipped, got %v and %d skipped", leases, stats.Skipped)
+	}
+
+	huge := "\nlease 172.16.0.62 {\n" + strings.Repeat("  binding state active;\n", 5000)
+	if _, err := ParseWithOptions(bytes.NewBufferString(huge), ParseOptions{}); !errors.Is(err, bufio.ErrTooLong) {
+		t.Errorf("expected bufio.ErrTooLong for an oversized unterminated block, got %v", err)
+	}
+}
+
+func TestParseRecoverPartial(t *testing.T) {
+	leaseData := `
+lease 172.16.0.60 {
+  binding state active;
+}
+lease 172.16.0.61 {
+  starts 2 2020/01/07 10:00:00;
+  binding state active;
+  hardware ethernet 00:db:70:c3:11:d7;
+  client-hostname "ger`
+
+	var stats ParseStats
+	leases, err := ParseWithOptions(bytes.NewBufferString(leaseData), ParseOptions{RecoverPartial: true, Offsets: true, Stats: &stats})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(leases) != 2 {
+		t.Fatalf("found %d leases, expected 2", len(leases))
+	}
+	if leases[0].Partial {
+		t.Error("complete lease shouldn't be partial")
+	}
+
+	l := leases[1]
+	if !l.Partial || l.IP.String() != "172.16.0.61" || l.BindingState != "active" || l.Hardware.MAC != "00:db:70:c3:11:d7" || l.Starts.IsZero() {
+		t.Errorf("unexpected partial lease %v", l)
+	}
+	if want := int64(strings.Index(leaseData, "lease 172.16.0.61")); l.Offset != want || l.Offset+int64(l.Length) != int64(len(leaseData)) {
+		t.Errorf("partial lease at %d+%d, expected it to run from %d to the end", l.Offset, l.Length, want)
+	}
+	if stats.Blocks != 2 || stats.Skipped != 0 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+
+	if leases, err := ParseWithOptions(bytes.NewBufferString(leaseData), ParseOptions{}); err != ErrTruncated || len(leases) != 1 {
+		t.Errorf("expected the partial lease to be dropped with ErrTruncated, got %d leases, %v", len(leases), err)
+	}
+}
+
+func TestParseLongStatement(t *testing.T) {
+	blob := strings.TrimSuffix(strings.Repeat("ab:", 30000), ":")
+	leaseData := `
+lease 172.16.0.60 {
+  binding state active;
+  option vendor-blob ` + blob + `;
+  client-hostname "after";
+}
+`
+	if _, err := ParseWithOptions(bytes.NewBufferString(leaseData), ParseOptions{}); !errors.Is(err, bufio.ErrTooLong) {
+		t.Errorf("expected bufio.ErrTooLong with the default block size, got %v", err)
+	}
+
+	leases, err := ParseWithOptions(bytes.NewBufferString(leaseData), ParseOptions{MaxBlockSize: 1 << 20})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(leases) != 1 {
+		t.Fatalf("found %d leases, expected 1", len(leases))
+	}
+	if got := leases[0].Options["vendor-blob"]; got != blob {
+		t.Errorf("option is %d bytes, expected %d", len(got), len(blob))
+	}
+	if leases[0].ClientHostname != "after" {
+		t.Errorf("hostname after the long statement is %q", leases[0].ClientHostname)
+	}
+}
+
+func TestParseOne(t *testing.T) {
+	l, err := ParseOne([]byte("lease 172.16.0.60 {\n  binding state active;\n}\n"))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if l.IP.String() != "172.16.0.60" || l.BindingState != "active" {
+		t.Errorf("unexpected lease %s %s", l.IP, l.BindingState)
+	}
+
+	for in, want := range map[string]error{
+		"":                                  ErrNoLease,
+		"# just a comment\n":                ErrNoLease,
+		"lease 172.16.0.60 {\n":             ErrTruncated,
+		braceFixture:                        ErrMultipleLeases,
+		"lease 172.16.0.60 {\n}\nlease 1 {": ErrTruncated,
+	} {
+		if _, err := ParseOne([]byte(in)); !errors.Is(err, want) {
+			t.Errorf("parsing %q gave %v, expected %v", in, err, want)
+		}
+	}
+}
+
+func TestParseNestedLease(t *testing.T) {
+	leaseData := `
+lease 172.16.0.60 {
+  binding state active;
+  lease 172.16.0.99 {
+    binding state free;
+    client-hostname "inner";
+  }
+  client-hostname "outer";
+}
+lease 172.16.0.61 {
+  binding state active;
+}
+`
+	var stats ParseStats
+	leases, err := ParseWithOptions(bytes.NewBufferString(leaseData), ParseOptions{Stats: &stats})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(leases) != 2 {
+		t.Fatalf("found %d leases, expected 2", len(leases))
+	}
+
+	// the nested block's statements don't overwrite the outer lease's
+	l := leases[0]
+	if l.IP.String() != "172.16.0.60" || l.ClientHostname != "outer" || l.BindingState != "active" {
+		t.Errorf("expected the outer lease, got %s %q %q", l.IP, l.ClientHostname, l.BindingState)
+	}
+	if len(l.Errors) != 1 || !errors.Is(l.Errors[0], ErrNestedLease) {
+		t.Errorf("expected a nested lease error, got %v", l.Errors)
+	}
+	if stats.Errors != 1 {
+		t.Errorf("expected 1 error in the stats, got %d", stats.Errors)
+	}
+	if leases[1].IP.String() != "172.16.0.61" || len(leases[1].Errors) != 0 {
+		t.Errorf("unexpected second lease %s %v", leases[1].IP, leases[1].Errors)
+	}
+}
+
+func TestParseUnclosedLease(t *testing.T) {
+	leaseData := `
+lease 172.16.0.60 {
+  binding state active;
+lease 172.16.0.61 {
+  binding state free;
+}
+lease 172.16.0.62 {
+  binding state active;
+}
+lease 172.16.0.63 {
+  binding state backup;
+}
+`
+	leases, err := ParseWithOptions(bytes.NewBufferString(leaseData), ParseOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	// a lease starting at the beginning of a line ends the unclosed lease before it
+	want := []struct{ ip, state string }{
+		{"172.16.0.60", "active"},
+		{"172.16.0.61", "free"},
+		{"172.16.0.62", "active"},
+		{"172.16.0.63", "backup"},
+	}
+	if len(leases) != len(want) {
+		t.Fatalf("found %d leases, expected %d: %v", len(leases), len(want), leases)
+	}
+	for i, w := range want {
+		if leases[i].IP.String() != w.ip || leases[i].BindingState != w.state {
+			t.Errorf("lease %d is %s %q, expected %s %q", i, leases[i].IP, leases[i].BindingState, w.ip, w.state)
+		}
+	}
+	if len(leases[0].Errors) != 1 || !errors.Is(leases[0].Errors[0], ErrNestedLease) {
+		t.Errorf("expected an error for the unclosed lease, got %v", leases[0].Errors)
+	}
+	for _, l := range leases[1:] {
+		if len(l.Errors) != 0 {
+			t.Errorf("unexpected errors for %s: %v", l.IP, l.Errors)
+		}
+	}
+}
+
+func TestParseNormalizeCase(t *testing.T) {
+	leaseData := `
+lease 172.16.0.60 {
+  binding state ACTIVE;
+  next binding state Free;
+  hardware ethernet 00:DB:70:C3:11:D7;
+  uid "\001ABC";
+  option Domain-Name "Example.COM";
+  client-hostname "Laptop";
+}
+`
+	raw := Parse(bytes.NewBufferString(leaseData))
+	if len(raw) != 1 || raw[0].Hardware.MAC != "00:DB:70:C3:11:D7" || raw[0].ClientHostname != "Laptop" {
+		t.Fatalf("fields should be kept as written by default, got %v", raw)
+	}
+
+	leases, _ := ParseWithOptions(bytes.NewBufferString(leaseData), ParseOptions{NormalizeCase: true})
+	if len(leases) != 1 {
+		t.Fatalf("found %d leases, expected 1", len(leases))
+	}
+	l := leases[0]
+	for _, f := range []struct{ got, want string }{
+		{l.BindingState, "active"},
+		{l.NextBindingState, "free"},
+		{l.Hardware.MAC, "00:db:70:c3:11:d7"},
+		{l.ClientHostname, "laptop"},
+		{l.Options["domain-name"], `"Example.COM"`},
+		{l.UID, `\001ABC`},
+	} {
+		if f.got != f.want {
+			t.Errorf("got %q, expected %q", f.got, f.want)
+		}
+	}
+}
+
+func TestParseLeaseLineExtraTokens(t *testing.T) {
+	leaseData := `
+lease 172.16.0.60 {  # renewed after the outage }
+  binding state active;
+}
+lease 172.16.0.61 active {
+  binding state active;
+}
+lease  172.16.0.62 {
+  binding state active;
+}
+lease 172.16.0.63{
+  binding state active;
+}
+`
+	leases := Parse(bytes.NewBufferString(leaseData))
+	want := []string{"172.16.0.60", "172.16.0.61", "172.16.0.62", "172.16.0.63"}
+	if len(leases) != len(want) {
+		t.Fatalf("found %d leases, expected %d", len(leases), len(want))
+	}
+	for i, ip := range want {
+		if leases[i].IP.String() != ip || leases[i].RawAddress != ip || leases[i].BindingState != "active" {
+			t.Errorf("lease %d is %s (%q) %s, expected %s", i, leases[i].IP, leases[i].RawAddress, leases[i].BindingState, ip)
+		}
+	}
+}
+
+func TestParseIrregularSpacing(t *testing.T) {
+	leaseData := `
+lease 172.16.0.60 {
+  binding  state   free ;
+  next binding state active ;
+  hardware ethernet 00:db:70:c3:11:d7;
+  uid 1:0:db:70:c3:11:d7 ;
+  client-hostname "two  spaces";
+}
+lease 172.16.0.61 {
+  binding state active;  
+}
+`
+	leases := Parse(bytes.NewBufferString(leaseData))
+	if len(leases) != 2 {
+		t.Fatalf("found %d leases, expected 2", len(leases))
+	}
+
+	l := leases[0]
+	if l.BindingState != "free" || l.NextBindingState != "active" {
+		t.Errorf("binding states are %q and %q, expected free and active", l.BindingState, l.NextBindingState)
+	}
+	if l.UIDLen() != 7 {
+		t.Errorf("uid %q decoded to %d bytes, expected 7", l.UID, l.UIDLen())
+	}
+	if l.ClientHostname != "two  spaces" {
+		t.Errorf("spaces inside quotes should be kept, got %q", l.ClientHostname)
+	}
+	if leases[1].BindingState != "active" {
+		t.Errorf("binding state with trailing spaces is %q, expected active", leases[1].BindingState)
+	}
+}
+
+func TestParseLenient(t *testing.T) {
+	leaseData := "lease 172.16.0.60 {\r\n" +
+		"\tstarts 2 2020/01/07 10:00:00; # renewed\r\n" +
+		"\tbinding\tstate active; # since the outage\r\n" +
+		"\thardware ethernet 00:db:70:c3:11:d7;\t \r\n" +
+		"  client-hostname \"tab\there # not a comment\"; # a comment\r\n" +
+		"\tuid 01:00:db:70:c3:11:d7; }\r\n" +
+		"lease 172.16.0.61 {\n" +
+		"  binding state free;\n" +
+		"}\n"
+
+	leases, err := ParseWithOptions(bytes.NewBufferString(leaseData), ParseOptions{Lenient: true})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(leases) != 2 {
+		t.Fatalf("found %d leases, expected 2", len(leases))
+	}
+
+	l := leases[0]
+	if want := time.Date(2020, 1, 7, 10, 0, 0, 0, time.UTC); !l.Starts.Equal(want) {
+		t.Errorf("starts %v, expected %v", l.Starts, want)
+	}
+	if l.BindingState != "active" || l.Hardware.MAC != "00:db:70:c3:11:d7" || l.UIDLen() != 7 {
+		t.Errorf("unexpected state %q, MAC %q or uid %q", l.BindingState, l.Hardware.MAC, l.UID)
+	}
+	if l.ClientHostname != "tab\there # not a comment" {
+		t.Errorf("quoted text should be kept as written, got %q", l.ClientHostname)
+	}
+	if len(l.Errors) != 0 {
+		t.Errorf("unexpected errors %v", l.Errors)
+	}
+	if leases[1].BindingState != "free" {
+		t.Errorf("second lease has binding state %q, expected free", leases[1].BindingState)
+	}
+
+	// strict parsing skips the tab indented statements
+	leases = Parse(bytes.NewBufferString(leaseData))
+	if len(leases) != 2 || leases[0].BindingState != "" || leases[0].ClientHostname == "" {
+		t.Errorf("expected only the space indented statements without Lenient, got %v", leases)
+	}
+}
+
+func TestParseStripLinePrefix(t *testing.T) {
+	leaseData := `Apr 27 03:24:45 host dhcpd[123]: DHCPACK on 172.16.0.60 to 00:db:70:c3:11:d7 via eth0
+Apr 27 03:24:45 host dhcpd[123]: lease 172.16.0.60 {
+Apr 27 03:24:45 host dhcpd[123]:   starts 6 2019/04/27 03:24:45;
+Apr 27 03:24:45 host dhcpd[123]:   binding state active;
+Apr 27 03:24:45 host dhcpd[123]:   hardware ethernet 00:db:70:c3:11:d7;
+Apr 27 03:24:45 host dhcpd[123]: }
+lease 172.16.0.61 {
+  binding state free;
+}
+`
+	opts := ParseOptions{StripLinePrefix: regexp.MustCompile(`^\w{3} +\d+ [\d:]{8} \S+ dhcpd\[\d+\]: `)}
+	leases, err := ParseWithOptions(bytes.NewBufferString(leaseData), opts)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(leases) != 2 {
+		t.Fatalf("found %d leases, expected 2", len(leases))
+	}
+	l := leases[0]
+	if l.IP.String() != "172.16.0.60" || l.BindingState != "active" || l.Hardware.MAC != "00:db:70:c3:11:d7" || l.Starts.IsZero() {
+		t.Errorf("unexpected lease %v", l)
+	}
+	if leases[1].IP.String() != "172.16.0.61" || leases[1].BindingState != "free" {
+		t.Errorf("unexpected lease without prefixes %v", leases[1])
+	}
+
+	if leases := Parse(bytes.NewBufferString(leaseData)); len(leases) != 1 {
+		t.Errorf("expected only the lease without prefixes to be found otherwise, got %v", leases)
+	}
+}
+
+func TestParseQuotedValues(t *testing.T) {
+	leaseData := `
+lease 172.16.0.60 {
+  client-hostname "my  host; with \"quotes\"";
+  option host-name "a b c"; # "not" the value
+}
+lease 172.16.0.61 {
+  uid "\001ab\"";
+  client-hostname "";
+}
+`
+	leases := Parse(bytes.NewBufferString(leaseData))
+	if len(leases) != 2 {
+		t.Fatalf("found %d leases, expected 2", len(leases))
+	}
+	if l := leases[0]; l.ClientHostname != `my  host; with \"quotes\"` || l.OptionHostName != "a b c" {
+		t.Errorf("unexpected hostnames %q and %q", l.ClientHostname, l.OptionHostName)
+	}
+	if l := leases[1]; !bytes.Equal(l.UIDBytes, []byte{1, 'a', 'b', '"'}) || l.ClientHostname != "" {
+		t.Errorf("unexpected uid %q or hostname %q", l.UIDBytes, l.ClientHostname)
+	}
+}
+
+func TestParseBOM(t *testing.T) {
+	leaseData := "\xef\xbb\xbflease 172.16.0.60 {\n  binding state active;\n}\nlease 172.16.0.61 {\n  binding state free;\n}\n"
+
+	leases, err := ParseWithOptions(iotest.OneByteReader(bytes.NewBufferString(leaseData)), ParseOptions{Offsets: true})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(leases) != 2 || leases[0].IP.String() != "172.16.0.60" || leases[1].IP.String() != "172.16.0.61" {
+		t.Fatalf("unexpected leases %v", leases)
+	}
+	if leases[0].Offset != 3 {
+		t.Errorf("first lease at offset %d, expected 3", leases[0].Offset)
+	}
+
+	path := filepath.Join(t.TempDir(), "dhcpd.leases")
+	if err := os.WriteFile(path, []byte(leaseData), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if leases, err := ParseFile(path); err != nil || len(leases) != 2 {
+		t.Errorf("expected 2 leases from the file, got %v, %v", leases, err)
+	}
+}
+
+func TestParseLeadingGarbage(t *testing.T) {
+	leaseData := strings.Repeat("# padding comment that isn't a lease\n", 5000) + `
+lease 172.16.0.60 {
+  binding state active;
+}
+`
+	leases, err := ParseWithOptions(bytes.NewBufferString(leaseData), ParseOptions{Offsets: true})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(leases) != 1 {
+		t.Fatalf("found %d leases, expected 1", len(leases))
+	}
+	if want := int64(strings.Index(leaseData, "lease 172")); leases[0].Offset != want {
+		t.Errorf("lease at offset %d, expected %d", leases[0].Offset, want)
+	}
+}
+
+func TestParseJunkBetweenBlocks(t *testing.T) {
+	leaseData := junkFixture
+	leases, err := ParseWithOptions(bytes.NewBufferString(leaseData), ParseOptions{Offsets: true})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	want := []struct {
+		ip    string
+		state string
+	}{
+		{"172.16.0.60", "active"},
+		{"172.16.0.61", "free"},
+		{"172.16.0.62", "backup"},
+	}
+	if len(leases) != len(want) {
+		t.Fatalf("found %d leases, expected %d", len(leases), len(want))
+	}
+	for i, w := range want {
+		l := leases[i]
+		if l.IP.String() != w.ip || l.BindingState != w.state {
+			t.Errorf("lease %d is %s %s, expected %s %s", i, l.IP, l.BindingState, w.ip, w.state)
+		}
+		block := leaseData[l.Offset : l.Offset+int64(l.Length)]
+		if !strings.HasPrefix(block, "lease "+w.ip+" {") || !strings.HasSuffix(block, "}") {
+			t.Errorf("lease %d has block %q", i, block)
+		}
+	}
+}
+
+func TestParseOpenBSD(t *testing.T) {
+	leases, err := ParseWithOptions(bytes.NewBufferString(openbsdFixture), ParseOptions{Dialect: DialectOpenBSD})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(leases) != 2 {
+		t.Fatalf("found %d leases, expected 2", len(leases))
+	}
+
+	l := leases[0]
+	if l.IP.String() != "192.168.1.100" || l.BindingState != "active" || l.HasBindingState() {
+		t.Errorf("unexpected address or binding state %s %q", l.IP, l.BindingState)
+	}
+	if want := time.Date(2020, 1, 8, 10, 0, 0, 0, time.UTC); !l.Ends.Equal(want) {
+		t.Errorf("ends %v, expected %v", l.Ends, want)
+	}
+	if l.Hardware.MAC != "00:db:70:c3:11:d7" || l.ClientHostname != "laptop" {
+		t.Errorf("unexpected hardware %q or hostname %q", l.Hardware.MAC, l.ClientHostname)
+	}
+	if !bytes.Equal(l.UIDBytes, []byte{0x01, 0x00, 0xdb, 0x70, 0xc3, 0x11, 0xd7}) {
+		t.Errorf("unexpected uid %v", l.UIDBytes)
+	}
+	if len(l.Errors) != 0 {
+		t.Errorf("unexpected errors %v", l.Errors)
+	}
+
+	l = leases[1]
+	if l.BindingState != "abandoned" || !l.Bootp {
+		t.Errorf("expected an abandoned bootp lease, got %q bootp %v", l.BindingState, l.Bootp)
+	}
+
+	// the ISC dialect doesn't expect tab indentation
+	leases, _ = ParseWithOptions(bytes.NewBufferString(openbsdFixture), ParseOptions{})
+	if len(leases) != 2 || leases[0].Hardware.MAC != "" {
+		t.Errorf("expected the tab indented statements to be skipped, got %v", leases)
+	}
+}
+
+func TestParseFrom(t *testing.T) {
+	all, err := ParseWithOptions(bytes.NewBufferString(braceFixture), ParseOptions{Offsets: true})
+	if err != nil || len(all) != 3 {
+		t.Fatalf("expected 3 leases, got %d, %v", len(all), err)
+	}
+
+	for _, tc := range []struct {
+		offset int64
+		want   []Lease
+	}{
+		{0, all},
+		{all[0].Offset + int64(all[0].Length), all[1:]},
+		// part way through the first and second lease blocks
+		{all[0].Offset + 10, all[1:]},
+		{all[1].Offset + 1, all[2:]},
+		{int64(len(braceFixture)), nil},
+	} {
+		leases, err := ParseFrom(strings.NewReader(braceFixture[tc.offset:]), tc.offset)
+		if err != nil {
+			t.Errorf("unexpected error from offset %d: %v", tc.offset, err)
+			continue
+		}
+		if len(leases) != len(tc.want) {
+			t.Errorf("found %d leases from offset %d, expected %d", len(leases), tc.offset, len(tc.want))
+			continue
+		}
+		for i := range leases {
+			if leases[i].Offset != tc.want[i].Offset || leases[i].IP.String() != tc.want[i].IP.String() {
+				t.Errorf("lease %s at %d from offset %d, expected %s at %d",
+					leases[i].IP, leases[i].Offset, tc.offset, tc.want[i].IP, tc.want[i].Offset)
+			}
+		}
+	}
+}
+
+func TestParseResume(t *testing.T) {
+	first := "lease 172.16.0.60 {\n  binding state active;\n}\n"
+	partial := "lease 172.16.0.61 {\n  binding state"
+	rest := " free;\n}\n"
+
+	cur := &Cursor{}
+	leases, err := ParseResume(strings.NewReader(first+partial), cur)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(leases) != 1 || leases[0].IP.String() != "172.16.0.60" {
+		t.Fatalf("expected the first lease, got %v", leases)
+	}
+	if want := int64(len(first) - 1); cur.Offset != want {
+		t.Errorf("cursor at %d, expected %d", cur.Offset, want)
+	}
+
+	leases, err = ParseResume(strings.NewReader(first+partial+rest), cur)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(leases) != 1 || leases[0].IP.String() != "172.16.0.61" || leases[0].BindingState != "free" {
+		t.Fatalf("expected the completed second lease, got %v", leases)
+	}
+	if want := int64(len(first+partial+rest) - 1); cur.Offset != want {
+		t.Errorf("cursor at %d, expected %d", cur.Offset, want)
+	}
+
+	// nothing new
+	leases, err = ParseResume(strings.NewReader(first+partial+rest), cur)
+	if err != nil || len(leases) != 0 {
+		t.Errorf("expected no leases, got %v, %v", leases, err)
+	}
+
+	// a replaced, shorter file is read from the start
+	leases, err = ParseResume(strings.NewReader(first), cur)
+	if err != nil || len(leases) != 1 || leases[0].IP.String() != "172.16.0.60" {
+		t.Errorf("expected the lease in the new file, got %v, %v", leases, err)
+	}
+}
+
+func TestParseRegion(t *testing.T) {
+	data := []byte(braceFixture)
+	all, err := ParseWithOptions(bytes.NewReader(data), ParseOptions{Offsets: true})
+	if err != nil || len(all) != 3 {
+		t.Fatalf("expected 3 leases, got %d, %v", len(all), err)
+	}
+
+	if leases := ParseRegion(data); !reflect.DeepEqual(leases, all) {
+		t.Errorf("whole file parsed as\n%v\nexpected\n%v", leases, all)
+	}
+
+	// a window starting part way into the first lease and ending part way into the last
+	start, end := all[0].Offset+5, all[2].Offset+10
+	leases := ParseRegion(data[start:end])
+	if len(leases) != 1 || leases[0].IP.String() != all[1].IP.String() {
+		t.Fatalf("expected only the middle lease, got %v", leases)
+	}
+	if leases[0].Offset != all[1].Offset-start || leases[0].Length != all[1].Length {
+		t.Errorf("lease at %d+%d, expected %d+%d", leases[0].Offset, leases[0].Length, all[1].Offset-start, all[1].Length)
+	}
+
+	// errors report the offset of their lease within the region, as Offset does
+	data = []byte("# leases\n\nlease 10.0.0.5 {\n  hardware ethernet;\n}\n")
+	leases = ParseRegion(data)
+	if len(leases) != 1 || len(leases[0].Errors) != 1 {
+		t.Fatalf("expected 1 lease with an error, got %v", leases)
+	}
+	var m *MalformedLeaseError
+	if !errors.As(leases[0].Errors[0], &m) || m.Offset != 10 || leases[0].Offset != 10 {
+		t.Errorf("error %v and lease at %d, expected both at offset 10", leases[0].Errors[0], leases[0].Offset)
+	}
+}
+
+func TestParseHardwareWithoutMAC(t *testing.T) {
+	leaseData := `
+lease 172.16.0.60 {
+  binding state active;
+  hardware ethernet;
+  client-hostname "after";
+}
+lease 172.16.0.61 {
+  hardware;
+}
+lease 172.16.0.62 {
+  hardware ethernet 00:db:70:c3:11:d7;
+}
+`
+	leases := Parse(bytes.NewBufferString(leaseData))
+	if len(leases) != 3 {
+		t.Fatalf("found %d leases, expected 3", len(leases))
+	}
+
+	l := leases[0]
+	if l.Hardware.Hardware != "ethernet" || l.Hardware.MAC != "" || l.Hardware.MACAddr != nil {
+		t.Errorf("unexpected hardware %+v", l.Hardware)
+	}
+	if len(l.Errors) != 1 || !errors.Is(l.Errors[0], ErrMalformedLease) || l.ClientHostname != "after" {
+		t.Errorf("expected only the hardware statement to be skipped, got %v", l)
+	}
+	// without even a type it isn't a hardware statement
+	if l := leases[1]; l.Hardware.Hardware != "" || l.Hardware.MAC != "" {
+		t.Errorf("unexpected hardware %+v", l.Hardware)
+	}
+	if l := leases[2]; l.Hardware.MACAddr.String() != "00:db:70:c3:11:d7" || len(l.Errors) != 0 {
+		t.Errorf("unexpected hardware %+v", l.Hardware)
+	}
+}
+
+func TestParseInvalidHexUID(t *testing.T) {
+	leaseData := `
+lease 172.16.0.60 {
+  uid 01:zz:ee;
+  client-hostname "m8";
+}
+lease 172.16.0.61 {
+  uid 01:00:ee;
+}
+`
+	leases := Parse(bytes.NewBufferString(leaseData))
+	if len(leases) != 2 {
+		t.Fatalf("found %d leases, expected 2", len(leases))
+	}
+
+	bad := leases[0]
+	if len(bad.Errors) != 1 {
+		t.Fatalf("expected one error for the invalid uid, got %v", bad.Errors)
+	}
+	if !strings.Contains(bad.Errors[0].Error(), "01:zz:ee") {
+		t.Errorf("error %q should name the invalid uid", bad.Errors[0])
+	}
+	if bad.UIDBytes != nil || bad.ClientHostname != "m8" {
+		t.Errorf("only the uid should be skipped, got %v", bad)
+	}
+
+	if len(leases[1].Errors) != 0 {
+		t.Errorf("valid uid shouldn't have errors, got %v", leases[1].Errors)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	leaseData := "lease 172.16.0.60 {\n  binding state active;\n}\nlease 172.16.0.61 {\n  uid 01:zz:ee;\n}\n"
+
+	leases, err := ParseWithOptions(bytes.NewBufferString(leaseData), ParseOptions{})
+	if err != nil || len(leases) != 2 {
+		t.Fatalf("expected 2 leases, got %d, %v", len(leases), err)
+	}
+	if len(leases[1].Errors) != 1 || !errors.Is(leases[1].Errors[0], ErrMalformedLease) {
+		t.Fatalf("expected a malformed lease error, got %v", leases[1].Errors)
+	}
+	var malformed *MalformedLeaseError
+	if !errors.As(leases[1].Errors[0], &malformed) {
+		t.Fatalf("expected a MalformedLeaseError, got %T", leases[1].Errors[0])
+	}
+	if want := int64(strings.Index(leaseData, "lease 172.16.0.61")); malformed.Offset != want || malformed.Line != "uid 01:zz:ee;" {
+		t.Errorf("error is for %q at offset %d, expected the uid at %d", malformed.Line, malformed.Offset, want)
+	}
+	if errors.Is(leases[1].Errors[0], ErrNestedLease) {
+		t.Error("the uid error shouldn't match ErrNestedLease")
+	}
+
+	broken := errors.New("connection reset")
+	r := io.MultiReader(strings.NewReader(leaseData[:30]), iotest.ErrReader(broken))
+	if _, err := ParseWithOptions(r, ParseOptions{}); !errors.Is(err, ErrIO) || !errors.Is(err, broken) || errors.Is(err, ErrTruncated) {
+		t.Errorf("expected an ErrIO wrapping the read error, got %v", err)
+	}
+
+	if _, err := ParseWithOptions(strings.NewReader(leaseData[:30]), ParseOptions{}); !errors.Is(err, ErrTruncated) || errors.Is(err, ErrIO) {
+		t.Errorf("expected ErrTruncated, got %v", err)
+	}
+
+	if _, err := ParseWithOptions(strings.NewReader(leaseData), ParseOptions{MaxBlockSize: 16}); !errors.Is(err, ErrTokenTooLarge) {
+		t.Errorf("expected ErrTokenTooLarge, got %v", err)
+	}
+}
+
+func TestParseLifetimes(t *testing.T) {
+	leaseData := `
+lease 172.16.0.60 {
+  binding state active;
+}
+lease 2001:db8::10 {
+  binding state active;
+  preferred-life 375;
+  max-life 600;
+}
+`
+	leases := Parse(bytes.NewBufferString(leaseData))
+	if len(leases) != 2 {
+		t.Fatalf("found %d leases, expected 2", len(leases))
+	}
+	if leases[0].PreferredLifetime != 0 || leases[0].MaxLifetime != 0 {
+		t.Errorf("v4 lease shouldn't have lifetimes, got %v", leases[0])
+	}
+	if leases[1].PreferredLifetime != 375*time.Second || leases[1].MaxLifetime != 10*time.Minute {
+		t.Errorf("unexpected lifetimes %v and %v", leases[1].PreferredLifetime, leases[1].MaxLifetime)
+	}
+}
+
+func TestParseOptionHostName(t *testing.T) {
+	leaseData := `
+lease 172.16.0.60 {
+  binding state active;
+  option host-name "printer";
+  client-hostname "PRN-01";
+}
+lease 172.16.0.61 {
+  binding state active;
+  client-hostname "laptop";
+}
+`
+	leases := Parse(bytes.NewBufferString(leaseData))
+	if len(leases) != 2 {
+		t.Fatalf("found %d leases, expected 2", len(leases))
+	}
+	if l := leases[0]; l.OptionHostName != "printer" || l.ClientHostname != "PRN-01" || l.Options["host-name"] != `"printer"` {
+		t.Errorf("unexpected host names %q, %q and option %q", l.OptionHostName, l.ClientHostname, l.Options["host-name"])
+	}
+	if l := leases[1]; l.OptionHostName != "" {
+		t.Errorf("expected no host-name option, got %q", l.OptionHostName)
+	}
+}
+
+func TestParseAssignments(t *testing.T) {
+	leaseData := `
+lease 172.16.0.60 {
+  binding state active;
+  set vendor-string = "ignored";
+  vendor-class-identifier = "MSFT 5.0";
+  lease-time =3600;
+}
+`
+	var unknown []string
+	leases, err := ParseWithOptions(bytes.NewBufferString(leaseData), ParseOptions{
+		OnUnknown: func(l *Lease, line string) { unknown = append(unknown, line) },
+	})
+	if err != nil || len(leases) != 1 {
+		t.Fatalf("expected 1 lease, got %d, %v", len(leases), err)
+	}
+	want := map[string]string{"vendor-class-identifier": `"MSFT 5.0"`}
+	if !reflect.DeepEqual(leases[0].Assignments, want) {
+		t.Errorf("assignments are %v, expected %v", leases[0].Assignments, want)
+	}
+	if len(unknown) != 1 || unknown[0] != "lease-time =3600;" {
+		t.Errorf("unexpected unknown statements %q", unknown)
+	}
+}
+
+func TestScanLeases(t *testing.T) {
+	data := []byte(braceFixture + "lease 172.16.0.220 {\n  binding state active;\n")
+	want := Parse(bytes.NewBufferString(braceFixture))
+
+	var blocks [][]byte
+	ScanLeases(data, func(start, end int) {
+		blocks = append(blocks, data[start:end])
+	})
+	if len(blocks) != len(want) {
+		t.Fatalf("found %d blocks, expected %d", len(blocks), len(want))
+	}
+	for i, b := range blocks {
+		l := Lease{}
+		l.parse(b, &ParseOptions{})
+		if l.IP.String() != want[i].IP.String() {
+			t.Errorf("block %d is lease %s, expected %s", i, l.IP, want[i].IP)
+		}
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		ScanLeases(data, func(start, end int) {})
+	})
+	if allocs != 0 {
+		t.Errorf("ScanLeases made %v allocations", allocs)
+	}
+}
+
+func TestParseBraceAtEOF(t *testing.T) {
+	leaseData := `# written without a trailing newline
+lease 172.16.0.60 {
+  binding state active;
+  client-hostname "m8";
+}`
+	for name, r := range map[string]io.Reader{
+		"reader":                 strings.NewReader(leaseData),
+		"eof returned with data": iotest.DataErrReader(strings.NewReader(leaseData)),
+		"one byte at a time":     iotest.OneByteReader(strings.NewReader(leaseData)),
+	} {
+		leases, err := ParseWithOptions(r, ParseOptions{})
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", name, err)
+		}
+		if len(leases) != 1 || leases[0].ClientHostname != "m8" {
+			t.Errorf("%s: expected the final lease, got %v", name, leases)
+		}
+	}
+}
+
+func TestParseOnUnknown(t *testing.T) {
+	leaseData := `
+lease 172.16.0.60 {
+  binding state active;
+  vendor-thing 42;
+  client-hostname "m8";
+}
+lease 172.16.0.61 {
+  other-thing "x";
+}
+`
+	unknown := map[string][]string{}
+	leases, err := ParseWithOptions(bytes.NewBufferString(leaseData), ParseOptions{
+		OnUnknown: func(l *Lease, line string) {
+			unknown[l.IP.String()] = append(unknown[l.IP.String()], line)
+		},
+	})
+	if err != nil || len(leases) != 2 {
+		t.Fatalf("expected 2 leases, got %d, %v", len(leases), err)
+	}
+
+	if got := unknown["172.16.0.60"]; len(got) != 1 || got[0] != "vendor-thing 42;" {
+		t.Errorf("unexpected unknown statements for 172.16.0.60 %q", got)
+	}
+	if got := unknown["172.16.0.61"]; len(got) != 1 || got[0] != `other-thing "x";` {
+		t.Errorf("unexpected unknown statements for 172.16.0.61 %q", got)
+	}
+	if len(unknown) != 2 {
+		t.Errorf("unexpected unknown statements %q", unknown)
+	}
+}
+
+func TestParseUnknownMap(t *testing.T) {
+	leaseData := `
+lease 172.16.0.60 {
+  binding state active;
+  secs 3;
+  vendor-thing "a b";
+  client-hostname "m8";
+}
+lease 172.16.0.61 {
+  binding state free;
+}
+`
+	leases := Parse(bytes.NewBufferString(leaseData))
+	if len(leases) != 2 {
+		t.Fatalf("found %d leases, expected 2", len(leases))
+	}
+	want := map[string]string{"secs": "3", "vendor-thing": `"a b"`}
+	if !reflect.DeepEqual(leases[0].Unknown, want) {
+		t.Errorf("unknown statements are %v, expected %v", leases[0].Unknown, want)
+	}
+	if leases[1].Unknown != nil {
+		t.Errorf("expected no unknown statements, got %v", leases[1].Unknown)
+	}
+}
+
+func TestParseStats(t *testing.T) {
+	leaseData := `
+lease 172.16.0.60 {
+  binding state active;
+  vendor-thing 42;
+  uid 01:zz;
+}
+lease 172.16.0.61 {
+  binding state active;
+  other-thing "x";
+  another-thing;
+}
+lease 172.16.0.62 {
+  binding state active;
+`
+	stats := ParseStats{}
+	leases, err := ParseWithOptions(bytes.NewBufferString(leaseData), ParseOptions{Stats: &stats})
+	if !errors.Is(err, ErrTruncated) {
+		t.Errorf("expected ErrTruncated, got %v", err)
+	}
+	if len(leases) != 2 {
+		t.Errorf("found %d leases, expected 2", len(leases))
+	}
+
+	want := ParseStats{Blocks: 3, Skipped: 1, Errors: 1, Unknown: 3, Bytes: int64(len(leaseData))}
+	if stats != want {
+		t.Errorf("stats %+v, expected %+v", stats, want)
+	}
+}
+
+func TestParseUIDMixedEscapes(t *testing.T) {
+	leaseData := `
+lease 172.16.0.60 {
+  uid "\001\x00\333p\xc3\021\xD7\\\"x";
+}
+`
+	leases := Parse(bytes.NewBufferString(leaseData))
+	if len(leases) != 1 {
+		t.Fatalf("found %d leases, expected 1", len(leases))
+	}
+	if want := []byte{0x01, 0x00, 0xdb, 'p', 0xc3, 0x11, 0xd7, '\\', '"', 'x'}; !bytes.Equal(leases[0].UIDBytes, want) {
+		t.Errorf("uid bytes %v, expected %v", leases[0].UIDBytes, want)
+	}
+}
+
+func TestParseRawAddress(t *testing.T) {
+	leaseData := `
+lease 172.16.0.60 {
+  binding state active;
+}
+lease host.example.com {
+  binding state active;
+}
+`
+	leases := Parse(bytes.NewBufferString(leaseData))
+	if len(leases) != 2 {
+		t.Fatalf("found %d leases, expected 2", len(leases))
+	}
+	if leases[0].RawAddress != "172.16.0.60" || leases[0].IP.String() != "172.16.0.60" {
+		t.Errorf("unexpected address %q, %v", leases[0].RawAddress, leases[0].IP)
+	}
+	if leases[1].RawAddress != "host.example.com" || leases[1].IP != nil {
+		t.Errorf("unexpected address %q, %v", leases[1].RawAddress, leases[1].IP)
+	}
+
+	again := Parse(bytes.NewBuffer(Marshal(leases)))
+	if len(again) != 2 || again[1].RawAddress != "host.example.com" {
+		t.Errorf("raw address should survive marshaling, got %v", again)
+	}
+}
+
+func TestParseBindingStateComment(t *testing.T) {
+	leaseData := `
+lease 172.16.0.60 {
+  binding state active; # renewed
+  client-hostname "host#1"; # not a comment inside the quotes
+  # reserved for the printer
+}
+`
+	leases := Parse(bytes.NewBufferString(leaseData))
+	if len(leases) != 1 || leases[0].BindingState != "active" {
+		t.Fatalf("expected 1 active lease, got %v", leases)
+	}
+	if leases[0].Comments != nil {
+		t.Errorf("expected no comments without KeepComments, got %q", leases[0].Comments)
+	}
+	if leases[0].Unknown != nil {
+		t.Errorf("expected the comment line not to be unknown without KeepComments, got %v", leases[0].Unknown)
+	}
+
+	leases, err := ParseWithOptions(bytes.NewBufferString(leaseData), ParseOptions{KeepComments: true})
+	if err != nil || len(leases) != 1 {
+		t.Fatalf("expected 1 lease, got %d, %v", len(leases), err)
+	}
+	l := leases[0]
+	if l.BindingState != "active" || l.ClientHostname != "host#1" {
+		t.Errorf("unexpected binding state %q and client-hostname %q", l.BindingState, l.ClientHostname)
+	}
+	want := []string{"renewed", "not a comment inside the quotes", "reserved for the printer"}
+	if !reflect.DeepEqual(l.Comments, want) {
+		t.Errorf("comments are %q, expected %q", l.Comments, want)
+	}
+	if l.Unknown != nil {
+		t.Errorf("expected the comment line not to be unknown, got %v", l.Unknown)
+	}
+}
+
+func TestParseTrailingComments(t *testing.T) {
+	leaseData := `
+lease 172.16.0.60 {
+  hardware ethernet 00:db:70:c3:11:d7; # nic
+  uid "\001\000\333p\303\021\327#"; # the '#' in the uid is kept
+  option routers 10.0.0.1; # gw
+  client-hostname "m8"; # desk
+}
+`
+	for _, opts := range []ParseOptions{{}, {KeepComments: true}} {
+		leases, err := ParseWithOptions(bytes.NewBufferString(leaseData), opts)
+		if err != nil || len(leases) != 1 {
+			t.Fatalf("expected 1 lease, got %d, %v", len(leases), err)
+		}
+		l := leases[0]
+		if l.Hardware.MAC != "00:db:70:c3:11:d7" || l.Hardware.MACAddr == nil {
+			t.Errorf("unexpected MAC %q", l.Hardware.MAC)
+		}
+		if want := []byte("\x01\x00\xdbp\xc3\x11\xd7#"); !bytes.Equal(l.UIDBytes, want) {
+			t.Errorf("uid bytes %q, expected %q", l.UIDBytes, want)
+		}
+		if ip, ok := l.OptionIP("routers"); !ok || ip.String() != "10.0.0.1" {
+			t.Errorf("unexpected routers option %q", l.Options["routers"])
+		}
+		if l.ClientHostname != "m8" {
+			t.Errorf("unexpected client-hostname %q", l.ClientHostname)
+		}
+		if l.Errors != nil || l.Unknown != nil {
+			t.Errorf("unexpected errors %v and unknown statements %v", l.Errors, l.Unknown)
+		}
+		if opts.KeepComments && len(l.Comments) != 4 {
+			t.Errorf("expected the 4 comments kept, got %q", l.Comments)
+		}
 	}
 }
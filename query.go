@@ -0,0 +1,256 @@
+package leases
+
+import (
+	"bytes"
+	"encoding/hex"
+	"net"
+	"sort"
+	"strings"
+	"time"
+)
+
+/*
+NextExpiry returns the lease that expires soonest after the given time.  Leases that never expire
+or have no recorded end are skipped.  false is returned if no lease expires after after.
+*/
+func NextExpiry(leases []Lease, after time.Time) (Lease, bool) {
+	var next Lease
+	found := false
+	for _, l := range leases {
+		if l.Ends.IsZero() || l.Ends.Equal(Never) || !l.Ends.After(after) {
+			continue
+		}
+		if !found || l.Ends.Before(next.Ends) {
+			next = l
+			found = true
+		}
+	}
+	return next, found
+}
+
+/*
+Filter returns the leases for which pred returns true, in their original order
+*/
+func Filter(leases []Lease, pred func(Lease) bool) []Lease {
+	var rtn []Lease
+	for _, l := range leases {
+		if pred(l) {
+			rtn = append(rtn, l)
+		}
+	}
+	return rtn
+}
+
+/*
+SortByEnds sorts leases in place by when they end, soonest first.  Leases that never end come after
+the rest, followed by leases with no recorded end such as abandoned leases.
+*/
+func SortByEnds(leases []Lease) {
+	sort.SliceStable(leases, func(i, j int) bool {
+		return timeBefore(leases[i].Ends, leases[j].Ends)
+	})
+}
+
+/*timeBefore orders times, with the zero time after every other*/
+func timeBefore(a, b time.Time) bool {
+	if a.IsZero() || b.IsZero() {
+		return !a.IsZero() && b.IsZero()
+	}
+	return a.Before(b)
+}
+
+/*
+Conflicts returns the current leases that are active and dynamically allocated, neither reserved
+nor BOOTP, but whose IP falls in one of the reserved ranges.  These indicate a pool overlapping
+static reservations.  The leases are sorted by IP.
+*/
+func Conflicts(leases []Lease, reserved []*net.IPNet) []Lease {
+	var rtn []Lease
+	for _, l := range CurrentByIP(leases) {
+		if l.BindingState != "active" || l.Reserved || l.Bootp {
+			continue
+		}
+		for _, n := range reserved {
+			if n.Contains(l.IP) {
+				rtn = append(rtn, l)
+				break
+			}
+		}
+	}
+	sortByIP(rtn)
+	return rtn
+}
+
+/*
+OverlappingLeases returns pairs of leases for the same IP whose Starts to Ends intervals overlap but
+which were given to different clients, which should never happen and points to a corrupt file or a
+split-brain failover pair.  A lease ending Never is open ended.  Leases without both times, a valid
+IP or a valid MAC are skipped, and MACs are compared by address rather than as written.  The pairs are
+sorted by IP, then by when the second lease starts.
+*/
+func OverlappingLeases(leases []Lease) [][2]Lease {
+	var sorted []Lease
+	for _, l := range leases {
+		if l.Starts.IsZero() || l.Ends.IsZero() || l.IP == nil || l.Hardware.MACAddr == nil {
+			continue
+		}
+		sorted = append(sorted, l)
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if c := bytes.Compare(sorted[i].IP.To16(), sorted[j].IP.To16()); c != 0 {
+			return c < 0
+		}
+		return sorted[i].Starts.Before(sorted[j].Starts)
+	})
+
+	var rtn [][2]Lease
+	// sweep each IP's leases in start order, comparing each with every earlier lease still open when
+	// it starts, so overlaps between leases other than the longest aren't missed
+	var open []Lease
+	for i, cur := range sorted {
+		if i > 0 && !sorted[i-1].IP.Equal(cur.IP) {
+			open = open[:0]
+		}
+		still := open[:0]
+		for _, o := range open {
+			if cur.Starts.Before(o.Ends) {
+				still = append(still, o)
+			}
+		}
+		open = still
+		for _, o := range open {
+			if !bytes.Equal(o.Hardware.MACAddr, cur.Hardware.MACAddr) {
+				rtn = append(rtn, [2]Lease{o, cur})
+			}
+		}
+		open = append(open, cur)
+	}
+	return rtn
+}
+
+/*
+InSubnet returns true if the lease's IP is within cidr.  IPv4 addresses match IPv4 subnets whether
+they are held in their 4 or 16 byte form.  Leases without an IP aren't in any subnet.
+*/
+func (l Lease) InSubnet(cidr *net.IPNet) bool {
+	if l.IP == nil || cidr == nil {
+		return false
+	}
+	return cidr.Contains(l.IP)
+}
+
+/*
+Family returns 4 or 6 for the address family of the lease's IP, or 0 if it has no valid IP.
+IPv4-mapped IPv6 addresses such as ::ffff:10.0.0.5 are IPv4, as net.IP holds every IPv4 address
+in that form.
+*/
+func (l Lease) Family() int {
+	switch {
+	case l.IP == nil:
+		return 0
+	case l.IP.To4() != nil:
+		return 4
+	}
+	return 6
+}
+
+/*
+FutureLeases returns the leases starting after now, which point to clock skew between servers or a
+misbehaving client.  Leases without a start time, or starting never, are skipped.
+*/
+func FutureLeases(leases []Lease, now time.Time) []Lease {
+	return Filter(leases, func(l Lease) bool {
+		return !l.Starts.IsZero() && !l.Starts.Equal(Never) && l.Starts.After(now)
+	})
+}
+
+/*
+FilterByMACPrefix returns the leases whose MAC address starts with prefix, such as the "00:db:70"
+OUI of a manufacturer.  The prefix may be written in either case, with colons, dashes or neither
+between octets, and octets may drop their leading zero.  Leases without a valid MAC are skipped.
+*/
+func FilterByMACPrefix(leases []Lease, prefix string) []Lease {
+	p := normalizeMACPrefix(prefix)
+	return Filter(leases, func(l Lease) bool {
+		return l.Hardware.MACAddr != nil && strings.HasPrefix(hex.EncodeToString(l.Hardware.MACAddr), p)
+	})
+}
+
+/*normalizeMACPrefix returns prefix as lower case hexadecimal without separators*/
+func normalizeMACPrefix(prefix string) string {
+	prefix = strings.ToLower(prefix)
+	if !strings.ContainsAny(prefix, ":-") {
+		return prefix
+	}
+	var sb strings.Builder
+	for _, o := range strings.FieldsFunc(prefix, func(r rune) bool { return r == ':' || r == '-' }) {
+		if len(o) == 1 {
+			sb.WriteByte('0')
+		}
+		sb.WriteString(o)
+	}
+	return sb.String()
+}
+
+/*
+OrphanLeases returns the current leases that are active but whose IP isn't in any of pools,
+pointing to a pool that has since been removed or a stale lease.  The leases are sorted by IP.
+*/
+func OrphanLeases(leases []Lease, pools []*net.IPNet) []Lease {
+	var rtn []Lease
+	for _, l := range CurrentByIP(leases) {
+		if l.BindingState != "active" {
+			continue
+		}
+		inPool := false
+		for _, p := range pools {
+			if l.InSubnet(p) {
+				inPool = true
+				break
+			}
+		}
+		if !inPool {
+			rtn = append(rtn, l)
+		}
+	}
+	sortByIP(rtn)
+	return rtn
+}
+
+/*
+ExpiringWithin returns the current leases that are active at now and end within d of it, soonest
+first then by IP, for finding clients due to renew.  Leases that never end or have already ended are skipped,
+as are leases whose IP has a later record that isn't active.
+*/
+func ExpiringWithin(leases []Lease, d time.Duration, now time.Time) []Lease {
+	until := now.Add(d)
+	var rtn []Lease
+	for _, l := range CurrentByIP(leases) {
+		if l.IsActive(now) && !l.Ends.Equal(Never) && !l.Ends.After(until) {
+			rtn = append(rtn, l)
+		}
+	}
+	// IP order first, so leases ending together keep a stable order
+	sortByIP(rtn)
+	SortByEnds(rtn)
+	return rtn
+}
+
+/*
+Timeline returns every record for ip, including the earlier records dhcpd keeps as a lease changes,
+sorted by when they start, for seeing which clients held an address and when.  Records with the
+same start stay in file order, and records without a start come last.  An empty slice is returned
+if ip has no records, or is nil, as records without a valid IP aren't for any address.
+*/
+func Timeline(leases []Lease, ip net.IP) []Lease {
+	rtn := []Lease{}
+	for _, l := range leases {
+		if l.IP != nil && l.IP.Equal(ip) {
+			rtn = append(rtn, l)
+		}
+	}
+	sort.SliceStable(rtn, func(i, j int) bool {
+		return timeBefore(rtn[i].Starts, rtn[j].Starts)
+	})
+	return rtn
+}
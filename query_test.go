@@ -0,0 +1,473 @@
+package leases
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestNextExpiry(t *testing.T) {
+	base := time.Date(2019, 4, 27, 3, 0, 0, 0, time.UTC)
+	leases := []Lease{
+		{IP: net.ParseIP("10.0.0.1"), Ends: base.Add(-time.Hour)},
+		{IP: net.ParseIP("10.0.0.2"), Ends: Never},
+		{IP: net.ParseIP("10.0.0.3"), Ends: base.Add(2 * time.Hour)},
+		{IP: net.ParseIP("10.0.0.4"), Ends: base.Add(time.Hour)},
+		{IP: net.ParseIP("10.0.0.5")},
+	}
+
+	l, ok := NextExpiry(leases, base)
+	if !ok {
+		t.Fatal("expected a lease to expire")
+	}
+	if l.IP.String() != "10.0.0.4" {
+		t.Errorf("%s shouldn't be the next to expire", l.IP)
+	}
+
+	if l, ok := NextExpiry(leases, base.Add(3*time.Hour)); ok {
+		t.Errorf("%s shouldn't expire after every other lease", l.IP)
+	}
+	if _, ok := NextExpiry(nil, base); ok {
+		t.Error("no leases shouldn't expire")
+	}
+}
+
+func TestSortByEnds(t *testing.T) {
+	base := time.Date(2019, 4, 27, 3, 0, 0, 0, time.UTC)
+	leases := []Lease{
+		{IP: net.ParseIP("10.0.0.1")},
+		{IP: net.ParseIP("10.0.0.2"), Ends: Never},
+		{IP: net.ParseIP("10.0.0.3"), Ends: base.Add(time.Hour)},
+		{IP: net.ParseIP("10.0.0.4"), Ends: base},
+		{IP: net.ParseIP("10.0.0.5")},
+	}
+
+	SortByEnds(leases)
+
+	for i, want := range []string{"10.0.0.4", "10.0.0.3", "10.0.0.2", "10.0.0.1", "10.0.0.5"} {
+		if leases[i].IP.String() != want {
+			t.Errorf("lease %d is %s, expected %s", i, leases[i].IP, want)
+		}
+	}
+}
+
+func TestAbandonedWithoutTimestamps(t *testing.T) {
+	leaseData := `
+lease 172.16.0.60 {
+  binding state abandoned;
+}
+lease 172.16.0.61 {
+  starts 4 2022/03/31 15:52:00;
+  ends 4 2022/03/31 19:52:00;
+  binding state active;
+}
+`
+	leases := Parse(bytes.NewBufferString(leaseData))
+	if len(leases) != 2 {
+		t.Fatalf("found %d leases, expected 2", len(leases))
+	}
+
+	abandoned := leases[0]
+	if abandoned.BindingState != "abandoned" || !abandoned.Starts.IsZero() || !abandoned.Ends.IsZero() {
+		t.Fatalf("unexpected abandoned lease %v", abandoned)
+	}
+	if abandoned.IsActive(time.Date(2022, 3, 31, 16, 0, 0, 0, time.UTC)) {
+		t.Error("abandoned lease shouldn't be active")
+	}
+	if abandoned.Duration() != 0 {
+		t.Errorf("abandoned lease shouldn't have a duration, got %v", abandoned.Duration())
+	}
+
+	SortByEnds(leases)
+	if leases[0].IP.String() != "172.16.0.61" || leases[1].IP.String() != "172.16.0.60" {
+		t.Errorf("abandoned lease should sort last, got %s then %s", leases[0].IP, leases[1].IP)
+	}
+	if !leases[0].IsActive(time.Date(2022, 3, 31, 16, 0, 0, 0, time.UTC)) {
+		t.Errorf("%v should be active", leases[0])
+	}
+}
+
+func TestConflicts(t *testing.T) {
+	leaseData := `
+lease 10.0.0.20 {
+  binding state active;
+}
+lease 10.0.0.5 {
+  binding state active;
+}
+lease 10.0.0.6 {
+  binding state active;
+  reserved;
+}
+lease 10.0.0.7 {
+  binding state active;
+  bootp;
+}
+lease 10.0.0.8 {
+  binding state free;
+}
+lease 10.0.0.9 {
+  binding state active;
+}
+lease 10.0.0.9 {
+  binding state free;
+}
+lease 10.0.0.4 {
+  binding state active;
+}
+lease 10.0.1.5 {
+  binding state active;
+}
+`
+	_, low, _ := net.ParseCIDR("10.0.0.0/28")
+	_, other, _ := net.ParseCIDR("192.168.0.0/24")
+
+	conflicts := Conflicts(Parse(bytes.NewBufferString(leaseData)), []*net.IPNet{other, low})
+
+	want := []string{"10.0.0.4", "10.0.0.5"}
+	if len(conflicts) != len(want) {
+		t.Fatalf("found %d conflicts, expected %d: %v", len(conflicts), len(want), conflicts)
+	}
+	for i, ip := range want {
+		if conflicts[i].IP.String() != ip {
+			t.Errorf("conflict %d is %s, expected %s", i, conflicts[i].IP, ip)
+		}
+	}
+}
+
+func TestOverlappingLeases(t *testing.T) {
+	leaseData := `
+lease 10.0.0.5 {
+  starts 6 2019/04/27 01:00:00;
+  ends 6 2019/04/27 02:00:00;
+  hardware ethernet 00:00:00:00:00:01;
+}
+lease 10.0.0.5 {
+  starts 6 2019/04/27 02:00:00;
+  ends 6 2019/04/27 03:00:00;
+  hardware ethernet 00:00:00:00:00:02;
+}
+lease 10.0.0.5 {
+  starts 6 2019/04/27 02:30:00;
+  ends 6 2019/04/27 03:30:00;
+  hardware ethernet 00:00:00:00:00:02;
+}
+lease 10.0.0.6 {
+  starts 6 2019/04/27 01:00:00;
+  ends never;
+  hardware ethernet 00:00:00:00:00:03;
+}
+lease 10.0.0.6 {
+  starts 6 2019/04/27 02:00:00;
+  ends 6 2019/04/27 02:10:00;
+  hardware ethernet 00:00:00:00:00:04;
+}
+lease 10.0.0.6 {
+  starts 6 2019/05/27 02:00:00;
+  ends 6 2019/05/27 02:10:00;
+  hardware ethernet 00:00:00:00:00:05;
+}
+lease 10.0.0.7 {
+  starts 6 2019/04/27 01:00:00;
+  ends 6 2019/04/27 02:00:00;
+  hardware ethernet 00:00:00:00:00:06;
+}
+lease 10.0.0.7 {
+  starts 6 2019/04/27 01:30:00;
+  ends 6 2019/04/27 02:30:00;
+  hardware ethernet 00:00:00:00:00:06;
+}
+`
+	pairs := OverlappingLeases(Parse(bytes.NewBufferString(leaseData)))
+
+	want := [][2]string{
+		{"00:00:00:00:00:03", "00:00:00:00:00:04"},
+		{"00:00:00:00:00:03", "00:00:00:00:00:05"},
+	}
+	if len(pairs) != len(want) {
+		t.Fatalf("found %d overlaps, expected %d: %v", len(pairs), len(want), pairs)
+	}
+	for i, w := range want {
+		if pairs[i][0].Hardware.MAC != w[0] || pairs[i][1].Hardware.MAC != w[1] {
+			t.Errorf("overlap %d is between %s and %s, expected %s and %s", i, pairs[i][0].Hardware.MAC, pairs[i][1].Hardware.MAC, w[0], w[1])
+		}
+	}
+}
+
+func TestOverlappingLeasesOpenIntervals(t *testing.T) {
+	leaseData := `
+lease 10.0.0.5 {
+  starts 6 2019/04/27 01:00:00;
+  ends 6 2019/04/27 10:00:00;
+  hardware ethernet 00:00:00:00:00:0a;
+}
+lease 10.0.0.5 {
+  starts 6 2019/04/27 02:00:00;
+  ends 6 2019/04/27 05:00:00;
+  hardware ethernet 00:00:00:00:00:0b;
+}
+lease 10.0.0.5 {
+  starts 6 2019/04/27 03:00:00;
+  ends 6 2019/04/27 08:00:00;
+  hardware ethernet 0:0:0:0:0:a;
+}
+lease foo {
+  starts 6 2019/04/27 01:00:00;
+  ends 6 2019/04/27 10:00:00;
+  hardware ethernet 00:00:00:00:00:0c;
+}
+lease bar {
+  starts 6 2019/04/27 02:00:00;
+  ends 6 2019/04/27 05:00:00;
+  hardware ethernet 00:00:00:00:00:0d;
+}
+`
+	pairs := OverlappingLeases(Parse(bytes.NewBufferString(leaseData)))
+
+	// the third lease overlaps the second, not only the first, which is the same client written
+	// differently, and leases without a valid IP don't overlap each other
+	want := [][2]string{
+		{"00:00:00:00:00:0a", "00:00:00:00:00:0b"},
+		{"00:00:00:00:00:0b", "0:0:0:0:0:a"},
+	}
+	if len(pairs) != len(want) {
+		t.Fatalf("found %d overlaps, expected %d: %v", len(pairs), len(want), pairs)
+	}
+	for i, w := range want {
+		if pairs[i][0].Hardware.MAC != w[0] || pairs[i][1].Hardware.MAC != w[1] {
+			t.Errorf("overlap %d is between %s and %s, expected %s and %s", i, pairs[i][0].Hardware.MAC, pairs[i][1].Hardware.MAC, w[0], w[1])
+		}
+	}
+}
+
+func TestFamily(t *testing.T) {
+	for _, tc := range []struct {
+		ip   net.IP
+		want int
+	}{
+		{net.ParseIP("10.0.0.5"), 4},
+		{net.ParseIP("10.0.0.5").To4(), 4},
+		{net.ParseIP("::ffff:10.0.0.5"), 4},
+		{net.ParseIP("2001:db8::5"), 6},
+		{nil, 0},
+	} {
+		if got := (Lease{IP: tc.ip}).Family(); got != tc.want {
+			t.Errorf("%v has family %d, expected %d", tc.ip, got, tc.want)
+		}
+	}
+}
+
+func TestInSubnet(t *testing.T) {
+	_, v4, _ := net.ParseCIDR("10.0.0.0/24")
+	_, v6, _ := net.ParseCIDR("2001:db8::/32")
+	mapped := &net.IPNet{IP: net.ParseIP("10.0.0.0"), Mask: net.CIDRMask(120, 128)}
+
+	for _, tc := range []struct {
+		ip   net.IP
+		cidr *net.IPNet
+		want bool
+	}{
+		{net.ParseIP("10.0.0.5"), v4, true},
+		{net.ParseIP("10.0.0.5").To4(), v4, true},
+		{net.ParseIP("10.0.1.5"), v4, false},
+		{net.ParseIP("10.0.0.5"), mapped, true},
+		{net.ParseIP("10.0.0.5").To4(), mapped, true},
+		{net.ParseIP("2001:db8::1"), v6, true},
+		{net.ParseIP("2001:db8::1"), v4, false},
+		{net.ParseIP("10.0.0.5"), v6, false},
+		{nil, v4, false},
+		{net.ParseIP("10.0.0.5"), nil, false},
+	} {
+		if got := (Lease{IP: tc.ip}).InSubnet(tc.cidr); got != tc.want {
+			t.Errorf("%v in %v is %v, expected %v", tc.ip, tc.cidr, got, tc.want)
+		}
+	}
+}
+
+func TestFutureLeases(t *testing.T) {
+	now := time.Date(2019, 4, 27, 3, 0, 0, 0, time.UTC)
+	leases := []Lease{
+		{IP: net.ParseIP("10.0.0.1"), Starts: now.Add(-time.Hour)},
+		{IP: net.ParseIP("10.0.0.2"), Starts: now.Add(time.Hour)},
+		{IP: net.ParseIP("10.0.0.3"), Starts: now},
+		{IP: net.ParseIP("10.0.0.4"), Starts: Never},
+		{IP: net.ParseIP("10.0.0.5"), Ends: now.Add(time.Hour)},
+		{IP: net.ParseIP("10.0.0.6"), Starts: now.Add(time.Second), Ends: Never},
+	}
+
+	future := FutureLeases(leases, now)
+	if len(future) != 2 || future[0].IP.String() != "10.0.0.2" || future[1].IP.String() != "10.0.0.6" {
+		t.Errorf("expected 10.0.0.2 and 10.0.0.6 to start in the future, got %v", future)
+	}
+}
+
+func TestFilterByMACPrefix(t *testing.T) {
+	leaseData := `
+lease 10.0.0.5 {
+  hardware ethernet 00:db:70:c3:11:d7;
+}
+lease 10.0.0.6 {
+  hardware ethernet 00:DB:70:00:00:01;
+}
+lease 10.0.0.7 {
+  hardware ethernet 00:db:71:c3:11:d7;
+}
+lease 10.0.0.8 {
+  binding state free;
+}
+`
+	leases := Parse(bytes.NewBufferString(leaseData))
+	for _, prefix := range []string{"00:db:70", "00:DB:70", "0:db:70", "00-db-70", "00db70"} {
+		got := FilterByMACPrefix(leases, prefix)
+		if len(got) != 2 || got[0].IP.String() != "10.0.0.5" || got[1].IP.String() != "10.0.0.6" {
+			t.Errorf("prefix %s matched %v, expected 10.0.0.5 and 10.0.0.6", prefix, got)
+		}
+	}
+	if got := FilterByMACPrefix(leases, "aa:bb"); len(got) != 0 {
+		t.Errorf("expected no matches, got %v", got)
+	}
+}
+
+func TestOrphanLeases(t *testing.T) {
+	leaseData := `
+lease 10.0.1.9 {
+  binding state active;
+}
+lease 10.0.0.5 {
+  binding state active;
+}
+lease 10.0.2.5 {
+  binding state active;
+}
+lease 10.0.2.5 {
+  binding state active;
+}
+lease 10.0.3.5 {
+  binding state free;
+}
+lease 10.0.4.5 {
+  binding state active;
+}
+lease 10.0.4.5 {
+  binding state expired;
+}
+lease 10.0.1.2 {
+  binding state active;
+}
+`
+	_, pool, _ := net.ParseCIDR("10.0.0.0/24")
+	_, other, _ := net.ParseCIDR("192.168.0.0/24")
+
+	orphans := OrphanLeases(Parse(bytes.NewBufferString(leaseData)), []*net.IPNet{other, pool})
+	want := []string{"10.0.1.2", "10.0.1.9", "10.0.2.5"}
+	if len(orphans) != len(want) {
+		t.Fatalf("found %d orphans, expected %d: %v", len(orphans), len(want), orphans)
+	}
+	for i, ip := range want {
+		if orphans[i].IP.String() != ip {
+			t.Errorf("orphan %d is %s, expected %s", i, orphans[i].IP, ip)
+		}
+	}
+}
+
+func TestExpiringWithin(t *testing.T) {
+	leaseData := `
+lease 10.0.0.1 {
+  ends 2 2020/01/07 10:30:00;
+  binding state active;
+}
+lease 10.0.0.2 {
+  ends 2 2020/01/07 10:10:00;
+  binding state active;
+}
+lease 10.0.0.3 {
+  ends 2 2020/01/07 09:50:00;
+  binding state active;
+}
+lease 10.0.0.4 {
+  ends never;
+  binding state active;
+}
+lease 10.0.0.5 {
+  ends 2 2020/01/07 13:00:00;
+  binding state active;
+}
+lease 10.0.0.6 {
+  ends 2 2020/01/07 10:20:00;
+  binding state active;
+}
+lease 10.0.0.6 {
+  ends 2 2020/01/07 10:20:00;
+  binding state free;
+}
+lease 10.0.0.1 {
+  ends 2 2020/01/07 10:40:00;
+  binding state active;
+}
+`
+	now := time.Date(2020, 1, 7, 10, 0, 0, 0, time.UTC)
+	expiring := ExpiringWithin(Parse(bytes.NewBufferString(leaseData)), time.Hour, now)
+
+	want := []string{"10.0.0.2", "10.0.0.1"}
+	if len(expiring) != len(want) {
+		t.Fatalf("found %d expiring leases, expected %d: %v", len(expiring), len(want), expiring)
+	}
+	for i, ip := range want {
+		if expiring[i].IP.String() != ip {
+			t.Errorf("expiring lease %d is %s, expected %s", i, expiring[i].IP, ip)
+		}
+	}
+	if !expiring[1].Ends.Equal(time.Date(2020, 1, 7, 10, 40, 0, 0, time.UTC)) {
+		t.Errorf("expected the latest record for 10.0.0.1, got one ending %v", expiring[1].Ends)
+	}
+}
+
+func TestTimeline(t *testing.T) {
+	leaseData := `
+lease 10.0.0.5 {
+  starts 2 2020/01/07 12:00:00;
+  hardware ethernet 00:db:70:c3:11:d8;
+  client-hostname "second";
+}
+lease 10.0.0.6 {
+  starts 2 2020/01/07 11:00:00;
+  client-hostname "other";
+}
+lease 10.0.0.5 {
+  starts 2 2020/01/07 10:00:00;
+  hardware ethernet 00:db:70:c3:11:d7;
+  client-hostname "first";
+}
+lease 10.0.0.5 {
+  binding state free;
+  client-hostname "nostart";
+}
+lease printer {
+  starts 2 2020/01/07 10:00:00;
+}
+`
+	leases := Parse(bytes.NewBufferString(leaseData))
+	timeline := Timeline(leases, net.ParseIP("10.0.0.5"))
+
+	want := []string{"first", "second", "nostart"}
+	if len(timeline) != len(want) {
+		t.Fatalf("found %d records, expected %d: %v", len(timeline), len(want), timeline)
+	}
+	for i, name := range want {
+		if timeline[i].ClientHostname != name {
+			t.Errorf("record %d is %q, expected %q", i, timeline[i].ClientHostname, name)
+		}
+	}
+	if timeline[0].Hardware.MAC != "00:db:70:c3:11:d7" {
+		t.Errorf("first record has MAC %q", timeline[0].Hardware.MAC)
+	}
+
+	if none := Timeline(leases, net.ParseIP("10.0.0.7")); none == nil || len(none) != 0 {
+		t.Errorf("expected an empty slice, got %#v", none)
+	}
+	// leases without a valid IP aren't the records of a missing IP
+	if none := Timeline(leases, nil); none == nil || len(none) != 0 {
+		t.Errorf("expected an empty slice for a nil IP, got %#v", none)
+	}
+}
@@ -0,0 +1,96 @@
+package leases
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+)
+
+/*
+Redact returns copies of the leases with the client identifiers replaced, for sharing a lease file
+without the details of the clients in it.  MACs, UIDs, client hostnames, the host-name option and
+host declaration names are replaced with an HMAC-SHA256 of the original keyed with key, so the same
+identifier always maps to the same replacement for a given key and leases for the same client can
+still be matched up.  Addresses, times, states, other options, assignments, unknown statements and
+comments are kept as they are, so check those hold nothing identifying before sharing the result.
+
+Redacted MACs keep their length and are marked locally administered.  UIDs keep their length and
+form, and the MAC in a uid of hardware type 1 gets the same replacement as in a hardware statement,
+so HardwareUIDMatch and ClientKey give the same answers as before.  Hostnames become "host-"
+followed by 8 hex digits.  Pick a key that isn't shared along with the
+leases, as anyone with the key can test guesses of the original identifiers.
+*/
+func Redact(leases []Lease, key []byte) []Lease {
+	rtn := make([]Lease, len(leases))
+	for i, l := range leases {
+		r := l.Clone()
+		switch {
+		case r.Hardware.MACAddr != nil:
+			// hash the decoded address so the same MAC written differently gets the same replacement
+			mac := redactMAC(key, r.Hardware.MACAddr)
+			r.Hardware.MACAddr = mac
+			r.Hardware.MAC = mac.String()
+		case r.Hardware.MAC != "":
+			r.Hardware.MAC = hex.EncodeToString(redactBytes(key, "mac", []byte(r.Hardware.MAC), 6))
+		}
+		hexList := isHexListUID(r)
+		switch {
+		case uidMAC(r.UIDBytes) != nil:
+			// a MAC in the uid gets the same replacement as in a hardware statement, so they still match
+			r.UIDBytes = append([]byte{1}, redactMAC(key, uidMAC(r.UIDBytes))...)
+		case r.UIDBytes != nil:
+			r.UIDBytes = redactBytes(key, "uid", r.UIDBytes, len(r.UIDBytes))
+		case r.UID != "":
+			r.UID = hex.EncodeToString(redactBytes(key, "uid", []byte(r.UID), 7))
+		}
+		switch {
+		case hexList:
+			r.UID = net.HardwareAddr(r.UIDBytes).String()
+		case r.UIDBytes != nil:
+			r.UID = escape(r.UIDBytes)
+		}
+		if r.ClientHostname != "" {
+			r.ClientHostname = redactName(key, r.ClientHostname)
+		}
+		if r.OptionHostName != "" {
+			r.OptionHostName = redactName(key, r.OptionHostName)
+			if r.Options != nil {
+				r.Options["host-name"] = "\"" + r.OptionHostName + "\""
+			}
+		}
+		if r.Host != "" {
+			r.Host = redactName(key, r.Host)
+		}
+		rtn[i] = r
+	}
+	return rtn
+}
+
+/*
+redactBytes returns n bytes of the HMAC of b keyed with key, repeating the HMAC for identifiers
+longer than it.  kind keeps a MAC and a UID with the same bytes from sharing a replacement
+*/
+func redactBytes(key []byte, kind string, b []byte, n int) []byte {
+	rtn := make([]byte, 0, n)
+	for block := byte(0); len(rtn) < n; block++ {
+		h := hmac.New(sha256.New, key)
+		h.Write([]byte(kind))
+		h.Write([]byte{0, block})
+		h.Write(b)
+		rtn = append(rtn, h.Sum(nil)...)
+	}
+	return rtn[:n]
+}
+
+/*redactMAC returns the replacement for mac, marked locally administered and unicast*/
+func redactMAC(key []byte, mac net.HardwareAddr) net.HardwareAddr {
+	rtn := net.HardwareAddr(redactBytes(key, "mac", mac, len(mac)))
+	rtn[0] = rtn[0]&^0x01 | 0x02
+	return rtn
+}
+
+/*redactName returns the replacement for a hostname*/
+func redactName(key []byte, name string) string {
+	return "host-" + hex.EncodeToString(redactBytes(key, "name", []byte(name), 4))
+}
@@ -0,0 +1,114 @@
+package leases
+
+import (
+	"bytes"
+	"net"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	leaseData := `
+lease 172.16.0.60 {
+  starts 2 2020/01/07 10:00:00;
+  binding state active;
+  hardware ethernet 00:db:70:c3:11:d7;
+  uid "\001\000\333p\303\021\327";
+  option host-name "laptop";
+  client-hostname "laptop";
+}
+lease 172.16.0.61 {
+  starts 2 2020/01/07 11:00:00;
+  binding state active;
+  hardware ethernet 00:DB:70:C3:11:D7;
+  client-hostname "laptop";
+}
+lease 172.16.0.62 {
+  binding state free;
+  hardware ethernet 00:db:70:c3:11:d8;
+}
+`
+	leases := Parse(bytes.NewBufferString(leaseData))
+	if len(leases) != 3 {
+		t.Fatalf("found %d leases, expected 3", len(leases))
+	}
+	key := []byte("secret")
+	redacted := Redact(leases, key)
+
+	r := redacted[0]
+	if r.IP.String() != "172.16.0.60" || !r.Starts.Equal(leases[0].Starts) || r.BindingState != "active" {
+		t.Errorf("expected the address, times and state to be kept, got %v", r)
+	}
+	if r.Hardware.MAC == leases[0].Hardware.MAC || len(r.Hardware.MACAddr) != 6 {
+		t.Errorf("expected a replacement MAC, got %q", r.Hardware.MAC)
+	}
+	if m, err := net.ParseMAC(r.Hardware.MAC); err != nil || !bytes.Equal(m, r.Hardware.MACAddr) || m[0]&0x03 != 0x02 {
+		t.Errorf("expected a locally administered unicast MAC, got %q", r.Hardware.MAC)
+	}
+	if len(r.UIDBytes) != 7 || bytes.Equal(r.UIDBytes, leases[0].UIDBytes) || !bytes.Equal(unescape(r.UID), r.UIDBytes) {
+		t.Errorf("expected a replacement uid, got %q %v", r.UID, r.UIDBytes)
+	}
+	if r.ClientHostname == "laptop" || r.ClientHostname != redacted[1].ClientHostname {
+		t.Errorf("expected the same replacement hostname, got %q and %q", r.ClientHostname, redacted[1].ClientHostname)
+	}
+	if r.OptionHostName != r.ClientHostname || r.Options["host-name"] != `"`+r.ClientHostname+`"` {
+		t.Errorf("expected the host-name option to be replaced too, got %q and %q", r.OptionHostName, r.Options["host-name"])
+	}
+
+	if match, ok := r.HardwareUIDMatch(); !ok || !match {
+		t.Errorf("expected the uid's MAC to match the redacted hardware MAC, got %v %v", match, ok)
+	}
+	if r.ClientKey() != redacted[1].ClientKey() {
+		t.Errorf("expected the client keys to still match, got %q and %q", r.ClientKey(), redacted[1].ClientKey())
+	}
+
+	if !r.SameHardware(redacted[1]) {
+		t.Errorf("expected the same MAC written differently to get the same replacement, got %q and %q", r.Hardware.MAC, redacted[1].Hardware.MAC)
+	}
+	if r.SameHardware(redacted[2]) {
+		t.Error("expected different MACs to get different replacements")
+	}
+
+	if again := Redact(leases, key); again[0].Hardware.MAC != r.Hardware.MAC {
+		t.Errorf("expected a stable replacement, got %q then %q", r.Hardware.MAC, again[0].Hardware.MAC)
+	}
+	if other := Redact(leases, []byte("other")); other[0].Hardware.MAC == r.Hardware.MAC {
+		t.Error("expected a different key to give a different replacement")
+	}
+	if leases[0].Hardware.MAC != "00:db:70:c3:11:d7" || leases[0].ClientHostname != "laptop" {
+		t.Error("expected the original leases to be left unchanged")
+	}
+}
+
+func TestRedactOptionHostNameWithoutOptions(t *testing.T) {
+	redacted := Redact([]Lease{{OptionHostName: "printer"}}, []byte("key"))
+	if redacted[0].OptionHostName == "printer" || redacted[0].Options != nil {
+		t.Errorf("unexpected redacted host name %q and options %v", redacted[0].OptionHostName, redacted[0].Options)
+	}
+}
+
+func TestRedactKeptFields(t *testing.T) {
+	leaseData := `
+lease 172.16.0.60 {
+  hardware ethernet 00:db:70:c3:11:d7;
+  uid 01:00:db:70:c3:11:d7;
+  vendor-class-identifier = "MSFT 5.0";
+  vendor-field laptop;
+  client-hostname "laptop"; # desk
+}
+`
+	leases, err := ParseWithOptions(bytes.NewBufferString(leaseData), ParseOptions{KeepComments: true})
+	if err != nil || len(leases) != 1 {
+		t.Fatalf("expected 1 lease, got %d, %v", len(leases), err)
+	}
+	r := Redact(leases, []byte("key"))[0]
+	if !isHexListUID(r) || bytes.Equal(r.UIDBytes, leases[0].UIDBytes) {
+		t.Errorf("expected a replacement uid written as a hex list, got %q", r.UID)
+	}
+	if match, ok := r.HardwareUIDMatch(); !ok || !match {
+		t.Errorf("expected the uid's MAC to match the redacted hardware MAC, got %v %v", match, ok)
+	}
+	// only the documented fields are redacted
+	if r.Assignments["vendor-class-identifier"] != `"MSFT 5.0"` || r.Unknown["vendor-field"] != "laptop" || len(r.Comments) != 1 || r.Comments[0] != "desk" {
+		t.Errorf("expected assignments, unknown statements and comments to be kept, got %v %v %q", r.Assignments, r.Unknown, r.Comments)
+	}
+}
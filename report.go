@@ -0,0 +1,127 @@
+package leases
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+)
+
+/*
+Report returns a human readable summary of leases at the given time: the number of lease records
+and current leases, counts of current leases by binding state, the number of unique clients, the
+oldest and newest active leases and, if subnets are given, the five subnets with the most active
+leases.
+*/
+func Report(leases []Lease, at time.Time, subnets ...*net.IPNet) string {
+	current := CurrentByIP(leases)
+
+	var active []Lease
+	states := map[string]int{}
+	clients := map[string]bool{}
+	for _, l := range current {
+		states[l.BindingState]++
+		if l.IsActive(at) {
+			active = append(active, l)
+		}
+		switch {
+		case l.Hardware.MACAddr != nil:
+			clients["mac "+l.Hardware.MACAddr.String()] = true
+		case l.UID != "":
+			clients["uid "+l.UID] = true
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Lease records: %d\n", len(leases))
+	fmt.Fprintf(&b, "Current leases: %d\n", len(current))
+	fmt.Fprintf(&b, "Active: %d\n", len(active))
+	fmt.Fprintf(&b, "Free: %d\n", states["free"])
+	fmt.Fprintf(&b, "Abandoned: %d\n", states["abandoned"])
+	fmt.Fprintf(&b, "Unique clients: %d\n", len(clients))
+
+	if len(active) > 0 {
+		// sort by IP first so leases starting together are reported the same way every time
+		sortByIP(active)
+		sort.SliceStable(active, func(i, j int) bool { return active[i].Starts.Before(active[j].Starts) })
+		oldest, newest := active[0], active[len(active)-1]
+		fmt.Fprintf(&b, "Oldest active lease: %s (started %s)\n", oldest.IP, oldest.Starts.Format(time.RFC3339))
+		fmt.Fprintf(&b, "Newest active lease: %s (started %s)\n", newest.IP, newest.Starts.Format(time.RFC3339))
+	}
+
+	if len(subnets) > 0 {
+		type usage struct {
+			subnet *net.IPNet
+			count  int
+		}
+		var usages []usage
+		for _, n := range subnets {
+			u := usage{subnet: n}
+			for _, l := range active {
+				if n.Contains(l.IP) {
+					u.count++
+				}
+			}
+			usages = append(usages, u)
+		}
+		sort.SliceStable(usages, func(i, j int) bool { return usages[i].count > usages[j].count })
+		if len(usages) > 5 {
+			usages = usages[:5]
+		}
+
+		b.WriteString("Top subnets:\n")
+		for _, u := range usages {
+			fmt.Fprintf(&b, "  %s: %d active\n", u.subnet, u.count)
+		}
+	}
+	return b.String()
+}
+
+/*
+UtilizationBuckets returns the number of IPs in cidr with an active lease during each bucket long
+period from start to end, for graphing how full a pool was over time.  Each lease record counts
+over its starts to ends interval, an open interval for leases that never end, so the earlier
+records dhcpd keeps as leases are renewed fill in the history.  An IP counts once per bucket
+however many of its records overlap it.  Records without a start or end time, or not in the active
+binding state, are skipped.  The last bucket is cut short at end if the period isn't a whole
+number of buckets, and nil is returned if bucket isn't positive or end isn't after start.
+*/
+func UtilizationBuckets(leases []Lease, cidr *net.IPNet, start, end time.Time, bucket time.Duration) []int {
+	if bucket <= 0 || !end.After(start) {
+		return nil
+	}
+	n := int((end.Sub(start) + bucket - 1) / bucket)
+	counts := make([]int, n)
+
+	buckets := map[string]map[int]bool{}
+	for _, l := range leases {
+		if l.BindingState != "active" || l.Starts.IsZero() || l.Ends.IsZero() || !l.InSubnet(cidr) {
+			continue
+		}
+		if !l.Starts.Before(end) || (!l.Ends.Equal(Never) && !l.Ends.After(start)) {
+			continue
+		}
+
+		first, last := 0, n-1
+		if l.Starts.After(start) {
+			first = int(l.Starts.Sub(start) / bucket)
+		}
+		if !l.Ends.Equal(Never) && l.Ends.Before(end) {
+			// the bucket the lease ends in, unless it ends exactly as the bucket starts
+			last = int((l.Ends.Sub(start) - 1) / bucket)
+		}
+
+		ip := l.IP.String()
+		if buckets[ip] == nil {
+			buckets[ip] = map[int]bool{}
+		}
+		for i := first; i <= last; i++ {
+			if !buckets[ip][i] {
+				buckets[ip][i] = true
+				counts[i]++
+			}
+		}
+	}
+	return counts
+}
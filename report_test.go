@@ -0,0 +1,145 @@
+package leases
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReport(t *testing.T) {
+	leaseData := `
+lease 10.0.0.5 {
+  starts 4 2022/03/31 15:00:00;
+  ends 4 2022/03/31 19:00:00;
+  binding state active;
+  hardware ethernet 00:00:00:00:00:01;
+}
+lease 10.0.0.6 {
+  starts 4 2022/03/31 16:00:00;
+  ends 4 2022/03/31 20:00:00;
+  binding state active;
+  hardware ethernet 00:00:00:00:00:02;
+}
+lease 10.0.1.7 {
+  starts 4 2022/03/31 15:30:00;
+  ends 4 2022/03/31 19:30:00;
+  binding state active;
+  hardware ethernet 00:00:00:00:00:02;
+}
+lease 10.0.0.8 {
+  starts 4 2022/03/31 12:00:00;
+  ends 4 2022/03/31 13:00:00;
+  binding state active;
+  hardware ethernet 00:00:00:00:00:03;
+}
+lease 10.0.0.8 {
+  binding state free;
+  hardware ethernet 00:00:00:00:00:03;
+}
+lease 10.0.0.9 {
+  binding state abandoned;
+}
+`
+	_, a, _ := net.ParseCIDR("10.0.0.0/24")
+	_, b, _ := net.ParseCIDR("10.0.1.0/24")
+
+	at := time.Date(2022, 3, 31, 17, 0, 0, 0, time.UTC)
+	got := Report(Parse(bytes.NewBufferString(leaseData)), at, b, a)
+
+	want := `Lease records: 6
+Current leases: 5
+Active: 3
+Free: 1
+Abandoned: 1
+Unique clients: 3
+Oldest active lease: 10.0.0.5 (started 2022-03-31T15:00:00Z)
+Newest active lease: 10.0.0.6 (started 2022-03-31T16:00:00Z)
+Top subnets:
+  10.0.0.0/24: 2 active
+  10.0.1.0/24: 1 active
+`
+	if got != want {
+		t.Errorf("unexpected report\n%s\nexpected\n%s", got, want)
+	}
+}
+
+func TestReportEqualStarts(t *testing.T) {
+	var b strings.Builder
+	for _, ip := range []string{"10.0.0.7", "10.0.0.5", "10.0.0.9", "10.0.0.6"} {
+		fmt.Fprintf(&b, "lease %s {\n  starts 4 2022/03/31 15:00:00;\n  ends 4 2022/03/31 19:00:00;\n  binding state active;\n}\n", ip)
+	}
+	leases := Parse(bytes.NewBufferString(b.String()))
+	at := time.Date(2022, 3, 31, 17, 0, 0, 0, time.UTC)
+
+	// leases starting together are ordered by IP, however the map of current leases is iterated
+	for i := 0; i < 20; i++ {
+		got := Report(leases, at)
+		if !strings.Contains(got, "Oldest active lease: 10.0.0.5 ") || !strings.Contains(got, "Newest active lease: 10.0.0.9 ") {
+			t.Fatalf("unexpected oldest and newest leases in\n%s", got)
+		}
+	}
+}
+
+func TestUtilizationBuckets(t *testing.T) {
+	leaseData := `
+lease 10.0.0.5 {
+  starts 2 2020/01/07 00:30:00;
+  ends 2 2020/01/07 02:00:00;
+  binding state active;
+}
+lease 10.0.0.6 {
+  starts 1 2020/01/06 23:00:00;
+  ends never;
+  binding state active;
+}
+lease 10.0.0.5 {
+  starts 2 2020/01/07 01:30:00;
+  ends 2 2020/01/07 03:15:00;
+  binding state active;
+}
+lease 10.0.0.7 {
+  starts 2 2020/01/07 02:00:00;
+  ends 2 2020/01/07 04:00:00;
+  binding state free;
+}
+lease 10.1.0.5 {
+  starts 2 2020/01/07 02:00:00;
+  ends 2 2020/01/07 04:00:00;
+  binding state active;
+}
+lease 10.0.0.8 {
+  starts 2 2020/01/07 05:30:00;
+  ends 2 2020/01/07 07:00:00;
+  binding state active;
+}
+lease 10.0.0.9 {
+  starts 1 2020/01/06 20:00:00;
+  ends 1 2020/01/06 22:00:00;
+  binding state active;
+}
+`
+	_, subnet, _ := net.ParseCIDR("10.0.0.0/24")
+	start := time.Date(2020, 1, 7, 0, 0, 0, 0, time.UTC)
+	leases := Parse(bytes.NewBufferString(leaseData))
+
+	got := UtilizationBuckets(leases, subnet, start, start.Add(6*time.Hour), time.Hour)
+	want := []int{2, 2, 2, 2, 1, 2}
+	if len(got) != len(want) {
+		t.Fatalf("found %d buckets, expected %d: %v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("bucket %d has %d, expected %d", i, got[i], want[i])
+		}
+	}
+
+	if got := UtilizationBuckets(leases, subnet, start, start.Add(90*time.Minute), time.Hour); len(got) != 2 || got[1] != 2 {
+		t.Errorf("expected a short last bucket, got %v", got)
+	}
+	if got := UtilizationBuckets(leases, subnet, start, start, time.Hour); got != nil {
+		t.Errorf("expected nil for an empty period, got %v", got)
+	}
+}
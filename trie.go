@@ -0,0 +1,163 @@
+package leases
+
+import (
+	"io"
+	"net"
+)
+
+/*
+IPTrie indexes the current lease for each IP by address, for answering repeated queries for the
+leases within a subnet without scanning every lease.  It is a path compressed binary trie over the
+16 byte form of each address, so IPv4 addresses sit under ::ffff:0:0/96.
+*/
+type IPTrie struct {
+	root *trieNode
+	size int
+}
+
+/*trieNode is a node of an IPTrie.  Leaves hold a lease and have bits of 128*/
+type trieNode struct {
+	//key is the address, masked to bits for nodes other than leaves
+	key [net.IPv6len]byte
+
+	//bits is the length of the prefix shared by everything under the node
+	bits int
+
+	lease    Lease
+	children [2]*trieNode
+}
+
+/*
+BuildIPTrie returns an IPTrie of the leases.  Later leases for an IP replace earlier ones, so the
+trie only holds the current lease for each.  Leases without a valid IP are skipped.
+*/
+func BuildIPTrie(leases []Lease) *IPTrie {
+	t := &IPTrie{}
+	for _, l := range leases {
+		t.insert(l)
+	}
+	return t
+}
+
+/*
+ParseIPTrie reads from a dhcpd.leases file and indexes the leases as they are parsed, without
+building the full list of leases.  Errors are returned as for ParseWithOptions, along with the
+leases indexed up to that point.
+*/
+func ParseIPTrie(r io.Reader) (*IPTrie, error) {
+	t := &IPTrie{}
+	err := parseLeases(r, ParseOptions{}, t.insert)
+	return t, err
+}
+
+/*Len returns the number of leases in the trie*/
+func (t *IPTrie) Len() int {
+	return t.size
+}
+
+/*Within returns the leases with an IP in cidr, sorted by IP*/
+func (t *IPTrie) Within(cidr *net.IPNet) []Lease {
+	ip := cidr.IP.To16()
+	ones, bits := cidr.Mask.Size()
+	if ip == nil || bits == 0 {
+		return nil
+	}
+	if bits == 8*net.IPv4len {
+		ones += 8 * (net.IPv6len - net.IPv4len)
+	}
+	var key [net.IPv6len]byte
+	copy(key[:], ip)
+
+	n := t.root
+	for n != nil {
+		if n.bits >= ones {
+			if prefixLen(n.key, key, ones) < ones {
+				return nil
+			}
+			var rtn []Lease
+			n.collect(&rtn)
+			return rtn
+		}
+		if prefixLen(n.key, key, n.bits) < n.bits {
+			return nil
+		}
+		n = n.children[bitAt(key, n.bits)]
+	}
+	return nil
+}
+
+func (t *IPTrie) insert(l Lease) {
+	ip := l.IP.To16()
+	if ip == nil {
+		return
+	}
+	leaf := &trieNode{bits: 8 * net.IPv6len, lease: l}
+	copy(leaf.key[:], ip)
+
+	p := &t.root
+	for {
+		n := *p
+		if n == nil {
+			*p = leaf
+			t.size++
+			return
+		}
+		common := prefixLen(n.key, leaf.key, n.bits)
+		if common < n.bits {
+			// the new address branches off part way along this node's prefix
+			split := &trieNode{key: maskKey(leaf.key, common), bits: common}
+			split.children[bitAt(n.key, common)] = n
+			split.children[bitAt(leaf.key, common)] = leaf
+			*p = split
+			t.size++
+			return
+		}
+		if n.bits == 8*net.IPv6len {
+			n.lease = l
+			return
+		}
+		p = &n.children[bitAt(leaf.key, n.bits)]
+	}
+}
+
+/*collect appends the leases under n to rtn in address order*/
+func (n *trieNode) collect(rtn *[]Lease) {
+	if n.bits == 8*net.IPv6len {
+		*rtn = append(*rtn, n.lease)
+		return
+	}
+	for _, c := range n.children {
+		if c != nil {
+			c.collect(rtn)
+		}
+	}
+}
+
+/*bitAt returns bit i of key, counting from the most significant bit*/
+func bitAt(key [net.IPv6len]byte, i int) int {
+	return int(key[i/8]>>(7-i%8)) & 1
+}
+
+/*prefixLen returns how many of the first max bits a and b have in common*/
+func prefixLen(a, b [net.IPv6len]byte, max int) int {
+	i := 0
+	// whole bytes first, then bit by bit through the first byte that differs
+	for i+8 <= max && a[i/8] == b[i/8] {
+		i += 8
+	}
+	for ; i < max; i++ {
+		if bitAt(a, i) != bitAt(b, i) {
+			return i
+		}
+	}
+	return max
+}
+
+/*maskKey returns key with everything after the first bits bits cleared*/
+func maskKey(key [net.IPv6len]byte, bits int) [net.IPv6len]byte {
+	var rtn [net.IPv6len]byte
+	for i := 0; i < bits; i++ {
+		rtn[i/8] |= byte(bitAt(key, i) << (7 - i%8))
+	}
+	return rtn
+}
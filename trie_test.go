@@ -0,0 +1,90 @@
+package leases
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"testing"
+)
+
+func TestIPTrie(t *testing.T) {
+	leaseData := `
+lease 10.0.0.5 {
+  binding state active;
+}
+lease 10.0.1.7 {
+  binding state active;
+}
+lease 10.0.0.200 {
+  binding state active;
+}
+lease 192.168.0.1 {
+  binding state active;
+}
+lease 2001:db8::1 {
+  binding state active;
+}
+lease 10.0.0.5 {
+  binding state free;
+}
+lease not-an-ip {
+  binding state active;
+}
+`
+	trie := BuildIPTrie(Parse(bytes.NewBufferString(leaseData)))
+	if trie.Len() != 5 {
+		t.Errorf("trie has %d leases, expected 5", trie.Len())
+	}
+	parsed, err := ParseIPTrie(bytes.NewBufferString(leaseData))
+	if err != nil || parsed.Len() != trie.Len() {
+		t.Errorf("parsed trie has %d leases, %v, expected %d", parsed.Len(), err, trie.Len())
+	}
+
+	for cidr, want := range map[string][]string{
+		"10.0.0.0/24":    {"10.0.0.5", "10.0.0.200"},
+		"10.0.0.0/16":    {"10.0.0.5", "10.0.0.200", "10.0.1.7"},
+		"10.0.0.128/25":  {"10.0.0.200"},
+		"10.0.0.5/32":    {"10.0.0.5"},
+		"0.0.0.0/0":      {"10.0.0.5", "10.0.0.200", "10.0.1.7", "192.168.0.1"},
+		"172.16.0.0/12":  nil,
+		"2001:db8::/32":  {"2001:db8::1"},
+		"::ffff:0:0/96":  {"10.0.0.5", "10.0.0.200", "10.0.1.7", "192.168.0.1"},
+		"2001:db9::/32":  nil,
+		"192.168.0.0/31": {"192.168.0.1"},
+	} {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, l := range trie.Within(n) {
+			got = append(got, l.IP.String())
+		}
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("leases within %s are %v, expected %v", cidr, got, want)
+		}
+	}
+
+	_, n, _ := net.ParseCIDR("10.0.0.5/32")
+	if l := trie.Within(n); len(l) != 1 || l[0].BindingState != "free" {
+		t.Errorf("expected the latest lease for 10.0.0.5, got %v", l)
+	}
+
+	if l := BuildIPTrie(nil).Within(n); l != nil {
+		t.Errorf("empty trie shouldn't have leases, got %v", l)
+	}
+}
+
+func BenchmarkIPTrieWithin(b *testing.B) {
+	var leases []Lease
+	for i := 0; i < 1<<16; i++ {
+		leases = append(leases, Lease{IP: net.IPv4(10, 0, byte(i>>8), byte(i))})
+	}
+	trie := BuildIPTrie(leases)
+	_, n, _ := net.ParseCIDR("10.0.42.0/24")
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		trie.Within(n)
+	}
+}
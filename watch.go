@@ -0,0 +1,280 @@
+package leases
+
+import (
+	"bytes"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+/*
+ChangeType is the kind of change a LeaseChange describes
+*/
+type ChangeType int
+
+const (
+	//LeaseAdded is a lease for an IP that had none before
+	LeaseAdded ChangeType = iota + 1
+
+	//LeaseUpdated is a new lease record for an IP replacing a different one
+	LeaseUpdated
+
+	//LeaseRemoved is an IP whose lease has gone, such as when dhcpd rewrites the file without it
+	LeaseRemoved
+)
+
+func (c ChangeType) String() string {
+	switch c {
+	case LeaseAdded:
+		return "added"
+	case LeaseUpdated:
+		return "updated"
+	case LeaseRemoved:
+		return "removed"
+	}
+	return "unknown"
+}
+
+/*
+LeaseChange describes a change to the current lease for an IP.  Old is the zero Lease for added
+leases and New is the zero Lease for removed ones.
+*/
+type LeaseChange struct {
+	IP   string
+	Old  Lease
+	New  Lease
+	Type ChangeType
+}
+
+/*
+Diff returns the changes from the current leases in before to those in after, sorted by IP.
+Leases are compared by the fields Marshal writes, so where a lease was parsed from doesn't count as
+a change.
+*/
+func Diff(before, after []Lease) []LeaseChange {
+	return diffCurrent(CurrentByIP(before), CurrentByIP(after))
+}
+
+/*diffCurrent returns the changes between two maps of current leases keyed by IP*/
+func diffCurrent(before, after map[string]Lease) []LeaseChange {
+	var changes []LeaseChange
+	for ip, n := range after {
+		o, ok := before[ip]
+		switch {
+		case !ok:
+			changes = append(changes, LeaseChange{IP: ip, New: n, Type: LeaseAdded})
+		case !sameLease(o, n):
+			changes = append(changes, LeaseChange{IP: ip, Old: o, New: n, Type: LeaseUpdated})
+		}
+	}
+	for ip, o := range before {
+		if _, ok := after[ip]; !ok {
+			changes = append(changes, LeaseChange{IP: ip, Old: o, Type: LeaseRemoved})
+		}
+	}
+	sortChanges(changes)
+	return changes
+}
+
+/*sortChanges sorts changes by IP*/
+func sortChanges(changes []LeaseChange) {
+	sort.SliceStable(changes, func(i, j int) bool {
+		return bytes.Compare(changeIP(changes[i]).To16(), changeIP(changes[j]).To16()) < 0
+	})
+}
+
+/*changeIP returns the address of the lease a change is for*/
+func changeIP(c LeaseChange) net.IP {
+	if c.Type == LeaseRemoved {
+		return c.Old.IP
+	}
+	return c.New.IP
+}
+
+/*sameLease reports whether a and b have the same content*/
+func sameLease(a, b Lease) bool {
+	var ab, bb bytes.Buffer
+	appendLease(&ab, a)
+	appendLease(&bb, b)
+	return bytes.Equal(ab.Bytes(), bb.Bytes())
+}
+
+/*
+Watcher polls a dhcpd.leases file and reports changes to the current lease for each IP.  Leases
+appended to the file are parsed incrementally.  If the file is replaced, such as by dhcpd rewriting
+it or logrotate moving it aside and creating a new one, or it shrinks, it is parsed again from the
+start and leases no longer in it are reported as removed.
+*/
+type Watcher struct {
+	path     string
+	interval time.Duration
+
+	changes chan LeaseChange
+	errors  chan error
+	done    chan struct{}
+	stopped chan struct{}
+	once    sync.Once
+
+	//state is the current lease for each IP
+	state map[string]Lease
+
+	//offset is the end of the last complete lease read from the file
+	offset int64
+
+	//file identifies the file last read, to tell when path has been replaced by another file
+	file os.FileInfo
+}
+
+/*
+Watch starts watching the dhcpd.leases file at path, checking it for changes every interval.  The
+leases already in the file are reported as added.  Both Changes and Errors must be received from
+for the watcher to make progress.
+*/
+func Watch(path string, interval time.Duration) (*Watcher, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+
+	w := &Watcher{
+		path:     path,
+		interval: interval,
+		changes:  make(chan LeaseChange),
+		errors:   make(chan error),
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+		state:    map[string]Lease{},
+	}
+	go w.run()
+	return w, nil
+}
+
+/*Changes returns the channel changes are sent on.  It is closed when the watcher is closed*/
+func (w *Watcher) Changes() <-chan LeaseChange {
+	return w.changes
+}
+
+/*Errors returns the channel errors reading the file are sent on.  It is closed when the watcher is closed*/
+func (w *Watcher) Errors() <-chan error {
+	return w.errors
+}
+
+/*Close stops the watcher and closes its channels*/
+func (w *Watcher) Close() error {
+	w.once.Do(func() { close(w.done) })
+	<-w.stopped
+	return nil
+}
+
+func (w *Watcher) run() {
+	defer close(w.stopped)
+	defer close(w.errors)
+	defer close(w.changes)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		changes, err := w.poll()
+		if err != nil {
+			log.WithFields(log.Fields{"path": w.path, "error": err}).Debug("Unable to read watched leases")
+			select {
+			case w.errors <- err:
+			case <-w.done:
+				return
+			}
+		}
+		for _, c := range changes {
+			select {
+			case w.changes <- c:
+			case <-w.done:
+				return
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-w.done:
+			return
+		}
+	}
+}
+
+/*poll reads any new leases from the file and returns the resulting changes*/
+func (w *Watcher) poll() ([]LeaseChange, error) {
+	f, err := os.Open(w.path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	// w.file is only updated once the file has been read, so a replacement that fails to load is
+	// read from the start again next time rather than from the old file's offset
+	replaced := w.file != nil && !os.SameFile(w.file, info)
+	switch {
+	case w.file == nil || replaced || info.Size() < w.offset:
+		// the first read goes through reload too, so only the current lease for each IP is added
+		// rather than every record leading up to it
+		changes, err := w.reload(f)
+		if err == nil {
+			w.file = info
+		}
+		return changes, err
+	case info.Size() == w.offset:
+		w.file = info
+		return nil, nil
+	}
+
+	if _, err := f.Seek(w.offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	leases, err := ParseFrom(f, w.offset)
+	if err != nil && err != ErrTruncated {
+		return nil, err
+	}
+	w.file = info
+
+	var changes []LeaseChange
+	for _, l := range leases {
+		ip := l.Key()
+		o, ok := w.state[ip]
+		switch {
+		case !ok:
+			changes = append(changes, LeaseChange{IP: ip, New: l, Type: LeaseAdded})
+		case !sameLease(o, l):
+			changes = append(changes, LeaseChange{IP: ip, Old: o, New: l, Type: LeaseUpdated})
+		}
+		w.state[ip] = l
+		w.offset = l.Offset + int64(l.Length)
+	}
+	return changes, nil
+}
+
+/*reload parses the whole file, when first read or after it has been replaced, and diffs it against the state*/
+func (w *Watcher) reload(f *os.File) ([]LeaseChange, error) {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	leases, err := ParseFrom(f, 0)
+	if err != nil && err != ErrTruncated {
+		return nil, err
+	}
+
+	current := CurrentByIP(leases)
+	changes := diffCurrent(w.state, current)
+	w.state = current
+	w.offset = 0
+	if len(leases) > 0 {
+		last := leases[len(leases)-1]
+		w.offset = last.Offset + int64(last.Length)
+	}
+	return changes, nil
+}
@@ -0,0 +1,216 @@
+package leases
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDiff(t *testing.T) {
+	before := Parse(bytes.NewBufferString(`
+lease 10.0.0.5 {
+  binding state active;
+}
+lease 10.0.0.6 {
+  binding state active;
+}
+lease 10.0.0.7 {
+  binding state active;
+}
+`))
+	after := Parse(bytes.NewBufferString(`
+lease 10.0.0.6 {
+  binding state active;
+}
+lease 10.0.0.7 {
+  binding state free;
+}
+lease 10.0.0.8 {
+  binding state active;
+}
+`))
+
+	changes := Diff(before, after)
+	want := []struct {
+		ip  string
+		typ ChangeType
+	}{
+		{"10.0.0.5", LeaseRemoved},
+		{"10.0.0.7", LeaseUpdated},
+		{"10.0.0.8", LeaseAdded},
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("found %d changes, expected %d: %v", len(changes), len(want), changes)
+	}
+	for i, w := range want {
+		if changes[i].IP != w.ip || changes[i].Type != w.typ {
+			t.Errorf("change %d is %s %s, expected %s %s", i, changes[i].Type, changes[i].IP, w.typ, w.ip)
+		}
+	}
+	if changes[1].Old.BindingState != "active" || changes[1].New.BindingState != "free" {
+		t.Errorf("unexpected update %v", changes[1])
+	}
+}
+
+func nextChange(t *testing.T, w *Watcher) LeaseChange {
+	t.Helper()
+	select {
+	case c := <-w.Changes():
+		return c
+	case err := <-w.Errors():
+		t.Fatalf("unexpected error %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a change")
+	}
+	return LeaseChange{}
+}
+
+func appendFile(t *testing.T, path, data string) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWatcherChanges(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dhcpd.leases")
+	initial := `
+lease 10.0.0.5 {
+  binding state active;
+}
+`
+	if err := os.WriteFile(path, []byte(initial), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := Watch(path, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	if c := nextChange(t, w); c.Type != LeaseAdded || c.IP != "10.0.0.5" {
+		t.Errorf("expected existing lease to be added, got %v", c)
+	}
+
+	// a partial block isn't reported until it is complete
+	appendFile(t, path, "lease 10.0.0.6 {\n  binding state active;\n")
+	time.Sleep(50 * time.Millisecond)
+	appendFile(t, path, "}\nlease 10.0.0.5 {\n  binding state free;\n}\n")
+
+	if c := nextChange(t, w); c.Type != LeaseAdded || c.IP != "10.0.0.6" {
+		t.Errorf("expected 10.0.0.6 to be added, got %v", c)
+	}
+	if c := nextChange(t, w); c.Type != LeaseUpdated || c.IP != "10.0.0.5" || c.New.BindingState != "free" {
+		t.Errorf("expected 10.0.0.5 to be updated, got %v", c)
+	}
+
+	// dhcpd rewriting the file with only current leases
+	if err := os.WriteFile(path, []byte("\nlease 10.0.0.6 {\n  binding state active;\n}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if c := nextChange(t, w); c.Type != LeaseRemoved || c.IP != "10.0.0.5" {
+		t.Errorf("expected 10.0.0.5 to be removed, got %v", c)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := <-w.Changes(); ok {
+		t.Error("changes should be closed")
+	}
+}
+
+func TestWatcherInitialHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dhcpd.leases")
+	initial := `
+lease 10.0.0.5 {
+  binding state active;
+}
+lease 10.0.0.6 {
+  binding state active;
+}
+lease 10.0.0.5 {
+  binding state free;
+}
+`
+	if err := os.WriteFile(path, []byte(initial), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := Watch(path, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	// only the current lease for each IP is added, not the records before it
+	if c := nextChange(t, w); c.Type != LeaseAdded || c.IP != "10.0.0.5" || c.New.BindingState != "free" {
+		t.Errorf("expected the current lease for 10.0.0.5 to be added, got %v", c)
+	}
+	if c := nextChange(t, w); c.Type != LeaseAdded || c.IP != "10.0.0.6" {
+		t.Errorf("expected 10.0.0.6 to be added, got %v", c)
+	}
+	select {
+	case c := <-w.Changes():
+		t.Errorf("unexpected change %v", c)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestWatcherRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dhcpd.leases")
+	if err := os.WriteFile(path, []byte("\nlease 10.0.0.5 {\n  binding state active;\n}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := Watch(path, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	if c := nextChange(t, w); c.Type != LeaseAdded || c.IP != "10.0.0.5" {
+		t.Errorf("expected existing lease to be added, got %v", c)
+	}
+
+	// keep the old file aside and put a new, larger one in its place, without a moment where path is
+	// missing
+	if err := os.Link(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	rotated := "\nlease 10.0.0.6 {\n  binding state active;\n}\nlease 10.0.0.7 {\n  binding state active;\n}\n"
+	if err := os.WriteFile(path+".new", []byte(rotated), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(path+".new", path); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		ip  string
+		typ ChangeType
+	}{
+		{"10.0.0.5", LeaseRemoved},
+		{"10.0.0.6", LeaseAdded},
+		{"10.0.0.7", LeaseAdded},
+	}
+	for _, wc := range want {
+		if c := nextChange(t, w); c.Type != wc.typ || c.IP != wc.ip {
+			t.Errorf("expected %s %s, got %v", wc.typ, wc.ip, c)
+		}
+	}
+}
+
+func TestWatchMissingFile(t *testing.T) {
+	if _, err := Watch(filepath.Join(t.TempDir(), "missing"), time.Second); !os.IsNotExist(err) {
+		t.Errorf("expected a not exist error, got %v", err)
+	}
+}
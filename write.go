@@ -0,0 +1,228 @@
+package leases
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+/*
+Write writes leases to w in the dhcpd.leases format, one lease block per lease.  Only the fields
+this package parses are written, so the output can be read back by Parse.  Non-printable bytes in
+the client identifier and hostname are written as octal escapes.
+*/
+func Write(w io.Writer, leases []Lease) error {
+	var b bytes.Buffer
+	for _, l := range leases {
+		b.Reset()
+		appendLease(&b, l)
+		if _, err := w.Write(b.Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+Marshal returns leases in the dhcpd.leases format, as written by Write
+*/
+func Marshal(leases []Lease) []byte {
+	var b bytes.Buffer
+	for _, l := range leases {
+		appendLease(&b, l)
+	}
+	return b.Bytes()
+}
+
+/*
+Bytes returns the lease as a single block in the dhcpd.leases format, as Write writes it, such as
+for appending an updated lease to a file.  ParseOne reads it back.
+*/
+func (l Lease) Bytes() []byte {
+	var b bytes.Buffer
+	appendLease(&b, l)
+	return b.Bytes()
+}
+
+/*
+WriteFiltered writes the leases matching pred to w in the dhcpd.leases format
+*/
+func WriteFiltered(w io.Writer, leases []Lease, pred func(Lease) bool) error {
+	return Write(w, Filter(leases, pred))
+}
+
+/*
+WriteCurrent writes the current lease for each IP to w, dropping the earlier records dhcpd appends
+as leases change, to produce a clean copy of a leases file.  The leases are sorted by sortBy, one of
+"ip", "ends", "starts" or "mac", with leases missing the field last and ties in IP order.  An error
+is returned for any other sortBy, before anything is written.
+*/
+func WriteCurrent(w io.Writer, leases []Lease, sortBy string) error {
+	var less func(a, b Lease) bool
+	switch sortBy {
+	case "ip":
+	case "ends":
+		less = func(a, b Lease) bool { return timeBefore(a.Ends, b.Ends) }
+	case "starts":
+		less = func(a, b Lease) bool { return timeBefore(a.Starts, b.Starts) }
+	case "mac":
+		less = func(a, b Lease) bool {
+			if a.Hardware.MACAddr == nil || b.Hardware.MACAddr == nil {
+				return a.Hardware.MACAddr != nil && b.Hardware.MACAddr == nil
+			}
+			return bytes.Compare(a.Hardware.MACAddr, b.Hardware.MACAddr) < 0
+		}
+	default:
+		return fmt.Errorf("unknown sort field %q", sortBy)
+	}
+
+	current := make([]Lease, 0, len(leases))
+	for _, l := range CurrentByIP(leases) {
+		current = append(current, l)
+	}
+	sortByIP(current)
+	if less != nil {
+		sort.SliceStable(current, func(i, j int) bool { return less(current[i], current[j]) })
+	}
+	return Write(w, current)
+}
+
+/*appendLease writes a single lease block to b*/
+func appendLease(b *bytes.Buffer, l Lease) {
+	if l.IP == nil && l.RawAddress != "" {
+		fmt.Fprintf(b, "lease %s {\n", l.RawAddress)
+	} else {
+		fmt.Fprintf(b, "lease %s {\n", l.IP)
+	}
+	for _, t := range []struct {
+		keyword string
+		time    time.Time
+	}{
+		{"starts", l.Starts},
+		{"ends", l.Ends},
+		{"tstp", l.Tstp},
+		{"tsfp", l.Tsfp},
+		{"atsfp", l.Atsfp},
+		{"cltt", l.Cltt},
+	} {
+		if !t.time.IsZero() {
+			fmt.Fprintf(b, "  %s %s;\n", t.keyword, formatTime(t.time))
+		}
+	}
+	if l.BindingState != "" {
+		fmt.Fprintf(b, "  binding state %s;\n", l.BindingState)
+	}
+	if l.NextBindingState != "" {
+		fmt.Fprintf(b, "  next binding state %s;\n", l.NextBindingState)
+	}
+	if l.RewindBindingState != "" {
+		fmt.Fprintf(b, "  rewind binding state %s;\n", l.RewindBindingState)
+	}
+	if l.Hardware.MAC != "" {
+		fmt.Fprintf(b, "  hardware %s %s;\n", l.Hardware.Hardware, l.Hardware.MAC)
+	}
+	switch {
+	case isHexListUID(l):
+		fmt.Fprintf(b, "  uid %s;\n", l.UID)
+	case l.UIDBytes != nil:
+		fmt.Fprintf(b, "  uid \"%s\";\n", escape(l.UIDBytes))
+	case l.UID != "":
+		fmt.Fprintf(b, "  uid \"%s\";\n", escapeRaw(l.UID))
+	}
+	if l.Reserved {
+		b.WriteString("  reserved;\n")
+	}
+	if l.Bootp {
+		b.WriteString("  bootp;\n")
+	}
+	if l.PreferredLifetime != 0 {
+		fmt.Fprintf(b, "  preferred-life %d;\n", l.PreferredLifetime/time.Second)
+	}
+	if l.MaxLifetime != 0 {
+		fmt.Fprintf(b, "  max-life %d;\n", l.MaxLifetime/time.Second)
+	}
+	names := make([]string, 0, len(l.Options))
+	for name := range l.Options {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(b, "  option %s %s;\n", name, escapeRaw(l.Options[name]))
+	}
+	names = names[:0]
+	for name := range l.Assignments {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(b, "  %s = %s;\n", name, escapeRaw(l.Assignments[name]))
+	}
+	if l.ClientHostname != "" {
+		fmt.Fprintf(b, "  client-hostname \"%s\";\n", escapeRaw(l.ClientHostname))
+	}
+	b.WriteString("}\n")
+}
+
+/*formatTime formats t the way dhcpd writes timestamps, "6 2019/04/27 03:34:45"*/
+func formatTime(t time.Time) string {
+	if t.Equal(Never) {
+		return "never"
+	}
+	t = t.UTC()
+	return fmt.Sprintf("%d %s", t.Weekday(), t.Format("2006/01/02 15:04:05"))
+}
+
+/*
+ToHostDeclaration returns a dhcpd.conf host declaration named name reserving the lease's IP for its
+client, for turning a dynamic lease into a static reservation:
+
+	host printer {
+		hardware ethernet 00:db:70:c3:11:d7;
+		fixed-address 10.0.0.5;
+	}
+
+The MAC is written in its normalised, lower case form, and fixed-address6 is used for IPv6
+addresses.  name is quoted if it isn't a plain identifier.  ErrNoHardware is returned if the lease
+has no valid MAC, and an error if it has no valid IP or name is empty.
+*/
+func ToHostDeclaration(l Lease, name string) (string, error) {
+	switch {
+	case l.Hardware.MACAddr == nil:
+		return "", ErrNoHardware
+	case l.IP == nil:
+		return "", fmt.Errorf("lease for %q has no valid IP", l.RawAddress)
+	case name == "":
+		return "", fmt.Errorf("no host name for %s", l.IP)
+	}
+
+	hardware := l.Hardware.Hardware
+	if hardware == "" {
+		hardware = "ethernet"
+	}
+	fixed := "fixed-address"
+	if l.IP.To4() == nil {
+		fixed = "fixed-address6"
+	}
+	if strings.IndexFunc(name, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.')
+	}) != -1 {
+		name = "\"" + escape([]byte(name)) + "\""
+	}
+	return fmt.Sprintf("host %s {\n\thardware %s %s;\n\t%s %s;\n}\n", name, hardware, l.Hardware.MACAddr, fixed, l.IP), nil
+}
+
+/*
+isHexListUID returns true if l's uid was read as a colon-separated hexadecimal list rather than a
+quoted string.  The text of a quoted uid never decodes as a hex list to the same bytes, as each
+octet takes at least one more character than the byte it stands for.
+*/
+func isHexListUID(l Lease) bool {
+	if l.UID == "" || l.UIDBytes == nil {
+		return false
+	}
+	b, err := parseHexList(l.UID)
+	return err == nil && bytes.Equal(b, l.UIDBytes)
+}
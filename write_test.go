@@ -0,0 +1,225 @@
+package leases
+
+import (
+	"bytes"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWriteRoundTrip(t *testing.T) {
+	leaseData := `
+lease 172.16.0.60 {
+  starts 4 2022/03/31 15:52:00;
+  ends 4 2022/03/31 19:52:00;
+  tstp 4 2022/03/31 19:52:00;
+  cltt 4 2022/03/31 15:52:00;
+  binding state active;
+  next binding state free;
+  rewind binding state free;
+  hardware ethernet 00:00:00:00:00:01;
+  uid "\001\000\356\275\264\276j";
+  client-hostname "m8";
+}
+lease 172.16.0.61 {
+  starts 4 2022/03/31 15:52:00;
+  ends never;
+  binding state backup;
+  uid "\377\"\305\\";
+  vendor-class-identifier = "MSFT 5.0";
+}
+`
+	leases := Parse(bytes.NewBufferString(leaseData))
+
+	var out bytes.Buffer
+	if err := Write(&out, leases); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if !strings.Contains(out.String(), "  hardware ethernet 00:00:00:00:00:01;\n") {
+		t.Errorf("hardware should be written as parsed:\n%s", out.String())
+	}
+
+	again := Parse(&out)
+	if !reflect.DeepEqual(leases, again) {
+		t.Errorf("leases changed writing and re-parsing them\n%v\n%v", leases, again)
+	}
+}
+
+func TestWriteFiltered(t *testing.T) {
+	_, subnet, _ := net.ParseCIDR("172.16.0.64/26")
+	leases := Parse(bytes.NewBufferString(braceFixture))
+
+	var out bytes.Buffer
+	err := WriteFiltered(&out, leases, func(l Lease) bool {
+		return l.BindingState == "active" && subnet.Contains(l.IP)
+	})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	filtered := Parse(&out)
+	if len(filtered) != 1 || filtered[0].IP.String() != "172.16.0.67" {
+		t.Errorf("expected only 172.16.0.67, got %v", filtered)
+	}
+}
+
+func TestMarshalEscapesNewline(t *testing.T) {
+	leaseData := `
+lease 172.16.0.60 {
+  uid "\012ab\012";
+  client-hostname "m8";
+}
+`
+	leases := Parse(bytes.NewBufferString(leaseData))
+	if want := []byte{0x0a, 'a', 'b', 0x0a}; !bytes.Equal(leases[0].UIDBytes, want) {
+		t.Fatalf("uid bytes %v, expected %v", leases[0].UIDBytes, want)
+	}
+
+	out := Marshal(leases)
+	if !bytes.Contains(out, []byte(`uid "\012ab\012";`)) {
+		t.Errorf("uid newlines should be written as octal escapes:\n%s", out)
+	}
+
+	again := Parse(bytes.NewBuffer(out))
+	if !reflect.DeepEqual(leases, again) {
+		t.Errorf("leases changed marshaling and re-parsing them\n%v\n%v", leases, again)
+	}
+
+	l := Lease{IP: net.ParseIP("172.16.0.61"), UID: "a\nb", ClientHostname: "host\nname"}
+	again = Parse(bytes.NewBuffer(Marshal([]Lease{l})))
+	if len(again) != 1 {
+		t.Fatalf("found %d leases, expected 1", len(again))
+	}
+	if !bytes.Equal(again[0].UIDBytes, []byte("a\nb")) {
+		t.Errorf("uid bytes %q, expected %q", again[0].UIDBytes, "a\nb")
+	}
+	if again[0].ClientHostname != `host\012name` {
+		t.Errorf("hostname %q should have its newline escaped", again[0].ClientHostname)
+	}
+}
+
+func TestWriteCurrent(t *testing.T) {
+	leaseData := `
+lease 10.0.0.9 {
+  starts 6 2019/04/27 03:00:00;
+  ends 6 2019/04/27 04:00:00;
+  hardware ethernet 00:00:00:00:00:03;
+}
+lease 10.0.0.10 {
+  starts 6 2019/04/27 01:00:00;
+  ends 6 2019/04/27 05:00:00;
+  hardware ethernet 00:00:00:00:00:01;
+}
+lease 10.0.0.9 {
+  starts 6 2019/04/27 02:00:00;
+  ends 6 2019/04/27 06:00:00;
+  hardware ethernet 00:00:00:00:00:02;
+}
+lease 10.0.0.8 {
+  binding state free;
+}
+`
+	leases := Parse(bytes.NewBufferString(leaseData))
+
+	for sortBy, want := range map[string][]string{
+		"ip":     {"10.0.0.8", "10.0.0.9", "10.0.0.10"},
+		"ends":   {"10.0.0.10", "10.0.0.9", "10.0.0.8"},
+		"starts": {"10.0.0.10", "10.0.0.9", "10.0.0.8"},
+		"mac":    {"10.0.0.10", "10.0.0.9", "10.0.0.8"},
+	} {
+		var out bytes.Buffer
+		if err := WriteCurrent(&out, leases, sortBy); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		var got []string
+		for _, l := range Parse(&out) {
+			got = append(got, l.IP.String())
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("sorted by %s got %v, expected %v", sortBy, got, want)
+		}
+	}
+
+	var out bytes.Buffer
+	if err := WriteCurrent(&out, leases, "hostname"); err == nil || out.Len() != 0 {
+		t.Errorf("expected an error and no output for an unknown sort field, got %v", err)
+	}
+}
+
+func TestLeaseBytes(t *testing.T) {
+	leases := Parse(bytes.NewBufferString(uidQuoteFixture))
+	if len(leases) == 0 {
+		t.Fatal("expected leases in the fixture")
+	}
+
+	for _, l := range leases {
+		b := l.Bytes()
+		if !bytes.HasPrefix(b, []byte("lease "+l.IP.String()+" {\n")) {
+			t.Errorf("unexpected block %q", b)
+		}
+		again, err := ParseOne(b)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", l.IP, err)
+			continue
+		}
+		if !bytes.Equal(again.UIDBytes, l.UIDBytes) || again.ClientHostname != l.ClientHostname || !again.Ends.Equal(l.Ends) {
+			t.Errorf("%s: read back as %v", l.IP, again)
+		}
+	}
+
+	l := Lease{IP: net.ParseIP("10.0.0.1"), UIDBytes: []byte{1, '\n', '"', 0xff}}
+	if b := l.Bytes(); !bytes.Contains(b, []byte(`uid "\001\012\"\377";`)) {
+		t.Errorf("binary uid should be escaped as octal, got %q", b)
+	}
+}
+
+func TestToHostDeclaration(t *testing.T) {
+	leaseData := `
+lease 10.0.0.5 {
+  binding state active;
+  hardware ethernet 00:DB:70:C3:11:D7;
+}
+lease 2001:db8::5 {
+  binding state active;
+  hardware ethernet 00:db:70:c3:11:d8;
+}
+lease 10.0.0.6 {
+  binding state active;
+}
+`
+	leases := Parse(bytes.NewBufferString(leaseData))
+	if len(leases) != 3 {
+		t.Fatalf("found %d leases, expected 3", len(leases))
+	}
+
+	decl, err := ToHostDeclaration(leases[0], "printer")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if want := "host printer {\n\thardware ethernet 00:db:70:c3:11:d7;\n\tfixed-address 10.0.0.5;\n}\n"; decl != want {
+		t.Errorf("got declaration\n%s\nexpected\n%s", decl, want)
+	}
+
+	decl, err = ToHostDeclaration(leases[1], "laptop's")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !strings.HasPrefix(decl, "host \"laptop's\" {\n") || !strings.Contains(decl, "\tfixed-address6 2001:db8::5;\n") {
+		t.Errorf("unexpected IPv6 declaration\n%s", decl)
+	}
+
+	// the declarations read back as reservations
+	hosts, err := ParseConfig(strings.NewReader(decl))
+	if err != nil || len(hosts) != 1 || hosts[0].Host != "laptop's" || hosts[0].IP.String() != "2001:db8::5" || !hosts[0].Reserved {
+		t.Errorf("declaration read back as %v, %v", hosts, err)
+	}
+
+	if _, err := ToHostDeclaration(leases[2], "nomac"); err != ErrNoHardware {
+		t.Errorf("expected ErrNoHardware, got %v", err)
+	}
+	if _, err := ToHostDeclaration(leases[0], ""); err == nil {
+		t.Error("expected an error for an empty name")
+	}
+}